@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+Режим -set - коррекция системных часов на измеренное смещение (для стендов без chrony/ntpd).
+Смещения меньше -step-threshold корректируются плавно (slew, adjtimex), большие - скачком (step).
+С -dry-run часы не изменяются, печатается только план коррекции.
+Для изменения часов нужны права root или CAP_SYS_TIME.
+
+Запуск:
+sudo go run . -set pool.ntp.org
+go run . -set -dry-run -step-threshold 500ms pool.ntp.org
+*/
+
+// defaultStepThreshold - порог перехода от плавной коррекции к скачку (STEPT в RFC 5905)
+const defaultStepThreshold = 128 * time.Millisecond
+
+// ErrClockUnsupported - изменение системных часов не поддерживается на этой платформе
+var ErrClockUnsupported = errors.New("setting the system clock is not supported on this platform")
+
+// Способы коррекции часов
+const (
+	methodNone = "none"
+	methodSlew = "slew"
+	methodStep = "step"
+)
+
+// Clock - системные часы. Реальная реализация - systemClock (clock_linux.go), в тестах - заглушка
+type Clock interface {
+	// Slew - плавная подстройка часов на offset
+	Slew(offset time.Duration) error
+	// Step - установка часов скачком на offset
+	Step(offset time.Duration) error
+}
+
+// Adjustment - план коррекции часов
+type Adjustment struct {
+	Method   string `json:"method"`
+	OffsetNs int64  `json:"offset_ns"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+}
+
+// planAdjustment - выбор способа коррекции: скачок, если |offset| больше порога, иначе плавная подстройка
+func planAdjustment(offset, stepThreshold time.Duration) Adjustment {
+	switch {
+	case offset == 0:
+		return Adjustment{Method: methodNone}
+	case offset > stepThreshold || offset < -stepThreshold:
+		return Adjustment{Method: methodStep, OffsetNs: int64(offset)}
+	default:
+		return Adjustment{Method: methodSlew, OffsetNs: int64(offset)}
+	}
+}
+
+// applyAdjustment - выполнение плана; при dryRun часы не изменяются
+func applyAdjustment(clock Clock, adj Adjustment, dryRun bool) (Adjustment, error) {
+	adj.DryRun = dryRun
+	if dryRun {
+		return adj, nil
+	}
+
+	var err error
+	switch adj.Method {
+	case methodSlew:
+		err = clock.Slew(time.Duration(adj.OffsetNs))
+	case methodStep:
+		err = clock.Step(time.Duration(adj.OffsetNs))
+	}
+	if err != nil {
+		return adj, fmt.Errorf("can not %s the clock: %w", adj.Method, err)
+	}
+	return adj, nil
+}
+
+// writeAdjustment - вывод выполненной или запланированной коррекции
+func writeAdjustment(w io.Writer, adj Adjustment, format string) error {
+	if format == formatJSON {
+		return json.NewEncoder(w).Encode(adj)
+	}
+
+	prefix := ""
+	if adj.DryRun {
+		prefix = "dry run: would "
+	}
+	var err error
+	switch adj.Method {
+	case methodNone:
+		_, err = fmt.Fprintln(w, prefix+"leave the clock unchanged")
+	default:
+		_, err = fmt.Fprintf(w, "%s%s the clock by %s\n", prefix, adj.Method, time.Duration(adj.OffsetNs))
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// adjOffsetSingleshot - режим adjtimex, аналогичный adjtime(3): однократная плавная подстройка
+const adjOffsetSingleshot = 0x8001
+
+// systemClock - системные часы Linux
+type systemClock struct{}
+
+// Slew - плавная подстройка через adjtimex, смещение передается в микросекундах
+func (systemClock) Slew(offset time.Duration) error {
+	tx := syscall.Timex{Modes: adjOffsetSingleshot, Offset: offset.Microseconds()}
+	_, err := syscall.Adjtimex(&tx)
+	return err
+}
+
+// Step - установка времени через settimeofday
+func (systemClock) Step(offset time.Duration) error {
+	tv := syscall.NsecToTimeval(time.Now().Add(offset).UnixNano())
+	return syscall.Settimeofday(&tv)
+}
@@ -0,0 +1,17 @@
+//go:build !linux || (!amd64 && !arm64)
+// +build !linux !amd64,!arm64
+
+package main
+
+import "time"
+
+// systemClock - заглушка для платформ без поддержки изменения часов
+type systemClock struct{}
+
+func (systemClock) Slew(offset time.Duration) error {
+	return ErrClockUnsupported
+}
+
+func (systemClock) Step(offset time.Duration) error {
+	return ErrClockUnsupported
+}
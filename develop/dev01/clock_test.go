@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeClock - часы, запоминающие вызовы вместо изменения системного времени
+type fakeClock struct {
+	calls []string
+	err   error
+}
+
+func (c *fakeClock) Slew(offset time.Duration) error {
+	c.calls = append(c.calls, "slew "+offset.String())
+	return c.err
+}
+
+func (c *fakeClock) Step(offset time.Duration) error {
+	c.calls = append(c.calls, "step "+offset.String())
+	return c.err
+}
+
+func TestPlanAdjustment(t *testing.T) {
+	testTable := []struct {
+		name   string
+		offset time.Duration
+		method string
+	}{
+		{name: "no offset", offset: 0, method: methodNone},
+		{name: "small positive", offset: 5 * time.Millisecond, method: methodSlew},
+		{name: "small negative", offset: -5 * time.Millisecond, method: methodSlew},
+		{name: "at threshold", offset: defaultStepThreshold, method: methodSlew},
+		{name: "large positive", offset: time.Second, method: methodStep},
+		{name: "large negative", offset: -time.Second, method: methodStep},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			adj := planAdjustment(testingCase.offset, defaultStepThreshold)
+			if adj.Method != testingCase.method {
+				t.Errorf("expected method '%s'; got '%s'", testingCase.method, adj.Method)
+			}
+		})
+	}
+}
+
+func TestApplyAdjustment(t *testing.T) {
+	clock := &fakeClock{}
+	adj, err := applyAdjustment(clock, planAdjustment(time.Second, defaultStepThreshold), true)
+	if err != nil || !adj.DryRun || len(clock.calls) != 0 {
+		t.Errorf("expected dry run without clock calls; got %+v, %v, %v", adj, err, clock.calls)
+	}
+
+	applyAdjustment(clock, planAdjustment(time.Second, defaultStepThreshold), false)
+	applyAdjustment(clock, planAdjustment(-time.Millisecond, defaultStepThreshold), false)
+	applyAdjustment(clock, planAdjustment(0, defaultStepThreshold), false)
+	if strings.Join(clock.calls, ", ") != "step 1s, slew -1ms" {
+		t.Errorf("expected 'step 1s, slew -1ms'; got '%s'", strings.Join(clock.calls, ", "))
+	}
+
+	clock = &fakeClock{err: syscall.EPERM}
+	_, err = applyAdjustment(clock, planAdjustment(time.Second, defaultStepThreshold), false)
+	if exitCode(err) != exitPermission {
+		t.Errorf("expected exit code %d for EPERM; got %d (%v)", exitPermission, exitCode(err), err)
+	}
+}
+
+func TestRunSet(t *testing.T) {
+	far := startStandIn(t, 2*time.Second, 0)
+	near := startStandIn(t, 20*time.Millisecond, 0)
+
+	testTable := []struct {
+		name   string
+		args   []string
+		calls  string
+		stdout string
+		code   int
+	}{
+		{name: "step", args: []string{"-set", far}, calls: "step ", stdout: "step the clock by ", code: exitOK},
+		{name: "slew", args: []string{"-set", near}, calls: "slew ", stdout: "slew the clock by ", code: exitOK},
+		{name: "custom threshold", args: []string{"-set", "-step-threshold", "5s", far}, calls: "slew ", stdout: "slew the clock by ", code: exitOK},
+		{name: "dry run", args: []string{"-set", "-dry-run", far}, stdout: "dry run: would step the clock by ", code: exitOK},
+		{name: "dry run without set", args: []string{"-dry-run", far}, code: exitUsage},
+		{name: "set with watch", args: []string{"-set", "-watch", "1s", far}, code: exitUsage},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			clock := &fakeClock{}
+			var stdout, stderr bytes.Buffer
+			code := runWithClock(testingCase.args, &stdout, &stderr, clock)
+			if code != testingCase.code {
+				t.Fatalf("expected exit code %d; got %d (stderr: '%s')", testingCase.code, code, stderr.String())
+			}
+			if calls := strings.Join(clock.calls, ", "); !strings.HasPrefix(calls, testingCase.calls) ||
+				(testingCase.calls == "" && calls != "") {
+				t.Errorf("expected clock calls starting with '%s'; got '%s'", testingCase.calls, calls)
+			}
+			if !strings.HasPrefix(stdout.String(), testingCase.stdout) {
+				t.Errorf("expected stdout starting with '%s'; got '%s'", testingCase.stdout, stdout.String())
+			}
+		})
+	}
+
+	var stdout bytes.Buffer
+	runWithClock([]string{"-set", "-dry-run", "-format", "json", far}, &stdout, &bytes.Buffer{}, &fakeClock{})
+	var adj Adjustment
+	if err := json.Unmarshal(stdout.Bytes(), &adj); err != nil || adj.Method != methodStep || !adj.DryRun {
+		t.Errorf("expected JSON step dry run; got '%s' (%v)", stdout.String(), err)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+Режим serve - локальный SNTPv4 сервер (RFC 4330) для интеграционных тестов и изолированных стендов.
+
+Запуск:
+go run . serve -port 1123 -stratum 1 -refid GPS
+go run . serve -port 1123 -skew 1.5s
+*/
+
+const (
+	packetSize = 48
+
+	modeClient = 3
+	modeServer = 4
+
+	// maxStratum - stratum 16 означает "не синхронизирован"
+	maxStratum = 15
+)
+
+// ntpEpoch - начало отсчета времени NTP
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ServerConfig - конфигурация SNTP сервера
+type ServerConfig struct {
+	// Addr - адрес для UDP, например ":123" или "127.0.0.1:0"
+	Addr string
+	// Stratum - уровень сервера, 1..15; 0 - по умолчанию 1
+	Stratum uint8
+	// ReferenceID - для stratum 1 - код источника до 4 ASCII символов ("GPS", "LOCL"),
+	// для остальных - IPv4 адрес вышестоящего сервера
+	ReferenceID string
+	// Skew - фиксированное смещение, добавляемое к системному времени в ответах
+	Skew time.Duration
+	// RootDispersion - сообщаемая клиентам погрешность относительно эталона
+	RootDispersion time.Duration
+	// ErrorLog - журнал ошибок отправки ответов; nil - log.Default()
+	ErrorLog *log.Logger
+}
+
+// Server - SNTP сервер, отвечающий на клиентские запросы
+type Server struct {
+	conf  ServerConfig
+	refID uint32
+	conn  *net.UDPConn
+	// now - источник времени, подменяется в тестах
+	now func() time.Time
+	// write - отправка ответа клиенту, подменяется в тестах
+	write func(b []byte, addr *net.UDPAddr) (int, error)
+
+	closeOnce sync.Once
+}
+
+// NewServer - конструктор сервера, проверяющий конфигурацию и открывающий UDP сокет
+func NewServer(conf ServerConfig) (*Server, error) {
+	if conf.Stratum == 0 {
+		conf.Stratum = 1
+	}
+	if conf.Stratum > maxStratum {
+		return nil, fmt.Errorf("invalid stratum %d: must be in 1..%d", conf.Stratum, maxStratum)
+	}
+	if conf.ReferenceID == "" {
+		conf.ReferenceID = "LOCL"
+	}
+	if conf.ErrorLog == nil {
+		conf.ErrorLog = log.Default()
+	}
+	if conf.RootDispersion < 0 {
+		return nil, errors.New("root dispersion must not be negative")
+	}
+
+	refID, err := parseReferenceID(conf.ReferenceID, conf.Stratum)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		conf:  conf,
+		refID: refID,
+		conn:  conn,
+		now:   time.Now,
+		write: conn.WriteToUDP,
+	}, nil
+}
+
+// parseReferenceID - преобразование идентификатора источника в 32-битное поле пакета
+func parseReferenceID(id string, stratum uint8) (uint32, error) {
+	if stratum > 1 {
+		ip := net.ParseIP(id).To4()
+		if ip == nil {
+			return 0, fmt.Errorf("reference id '%s' must be an IPv4 address for stratum %d", id, stratum)
+		}
+		return binary.BigEndian.Uint32(ip), nil
+	}
+
+	if len(id) > 4 {
+		return 0, fmt.Errorf("reference id '%s' is longer than 4 characters", id)
+	}
+	var code [4]byte
+	for i := 0; i < len(id); i++ {
+		if id[i] < 32 || id[i] > 126 {
+			return 0, fmt.Errorf("reference id '%s' must be printable ASCII", id)
+		}
+		code[i] = id[i]
+	}
+	return binary.BigEndian.Uint32(code[:]), nil
+}
+
+// Addr - фактический адрес сервера (полезно при Addr с портом 0)
+func (s *Server) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// Serve - цикл обработки запросов, завершается после Close или при ошибке чтения из сокета.
+// Ошибка отправки ответа одному клиенту (например, недоступному) записывается в ErrorLog,
+// обслуживание остальных продолжается
+func (s *Server) Serve() error {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		receiveTime := s.now().Add(s.conf.Skew)
+
+		resp, ok := s.response(buf[:n], receiveTime)
+		if !ok {
+			continue
+		}
+		if _, err := s.write(resp, addr); err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.conf.ErrorLog.Printf("can not reply to %s: %s", addr, err.Error())
+		}
+	}
+}
+
+// Close - остановка сервера
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() { err = s.conn.Close() })
+	return err
+}
+
+// response - формирование ответа на запрос; ok == false - запрос не клиентский, ответ не нужен
+func (s *Server) response(req []byte, receiveTime time.Time) ([]byte, bool) {
+	if len(req) < packetSize {
+		return nil, false
+	}
+	version := (req[0] >> 3) & 0x07
+	mode := req[0] & 0x07
+	if mode != modeClient || version < 1 || version > 4 {
+		return nil, false
+	}
+
+	resp := make([]byte, packetSize)
+	// LI = 0 (без предупреждений), версия - как в запросе, режим "сервер"
+	resp[0] = version<<3 | modeServer
+	resp[1] = s.conf.Stratum
+	resp[2] = req[2]          // poll - как в запросе
+	resp[3] = uint8(256 - 20) // precision 2^-20 с (~1 мкс)
+	binary.BigEndian.PutUint32(resp[4:], 0)
+	binary.BigEndian.PutUint32(resp[8:], toNtpShort(s.conf.RootDispersion))
+	binary.BigEndian.PutUint32(resp[12:], s.refID)
+	// часы сервера сами являются эталоном, поэтому время последней синхронизации - текущее
+	binary.BigEndian.PutUint64(resp[16:], toNtpTime(receiveTime))
+	// origin = transmit time клиента
+	copy(resp[24:32], req[40:48])
+	binary.BigEndian.PutUint64(resp[32:], toNtpTime(receiveTime))
+	binary.BigEndian.PutUint64(resp[40:], toNtpTime(s.now().Add(s.conf.Skew)))
+	return resp, true
+}
+
+// toNtpTime - время в 64-битном формате NTP: 32 бита секунд и 32 бита долей секунды
+func toNtpTime(t time.Time) uint64 {
+	nsec := uint64(t.Sub(ntpEpoch))
+	sec := nsec / uint64(time.Second)
+	frac := (nsec - sec*uint64(time.Second)) << 32 / uint64(time.Second)
+	return sec<<32 | frac
+}
+
+// toNtpShort - интервал в 32-битном формате NTP: 16 бит секунд и 16 бит долей секунды
+func toNtpShort(d time.Duration) uint32 {
+	return uint32(d * (1 << 16) / time.Second)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+func TestServerQuery(t *testing.T) {
+	server, err := NewServer(ServerConfig{
+		Addr:           "127.0.0.1:0",
+		Stratum:        2,
+		ReferenceID:    "10.0.0.1",
+		Skew:           -3 * time.Second,
+		RootDispersion: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer server.Close()
+	go server.Serve()
+
+	host, port, _ := splitServer(server.Addr())
+	for _, version := range []int{3, 4} {
+		response, err := ntp.QueryWithOptions(host, ntp.QueryOptions{Port: port, Version: version, Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("version %d: expected err == nil; got '%s'", version, err.Error())
+		}
+		if err := response.Validate(); err != nil {
+			t.Errorf("version %d: expected valid response; got '%s'", version, err.Error())
+		}
+
+		if diff := response.ClockOffset + 3*time.Second; diff > 10*time.Millisecond || diff < -10*time.Millisecond {
+			t.Errorf("version %d: expected offset near -3s; got %s", version, response.ClockOffset)
+		}
+		if response.Stratum != 2 {
+			t.Errorf("version %d: expected stratum 2; got %d", version, response.Stratum)
+		}
+		if response.ReferenceID != 0x0a000001 {
+			t.Errorf("version %d: expected reference id 0x0a000001; got %#x", version, response.ReferenceID)
+		}
+		if response.RootDispersion < 9*time.Millisecond || response.RootDispersion > 10*time.Millisecond {
+			t.Errorf("version %d: expected root dispersion 10ms; got %s", version, response.RootDispersion)
+		}
+	}
+}
+
+func TestServerIgnoresNonClientPackets(t *testing.T) {
+	server, err := NewServer(ServerConfig{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	req := make([]byte, packetSize)
+	if _, ok := server.response(req[:20], time.Now()); ok {
+		t.Errorf("expected short packet to be ignored")
+	}
+
+	req[0] = 4<<3 | modeServer
+	if _, ok := server.response(req, time.Now()); ok {
+		t.Errorf("expected server mode packet to be ignored")
+	}
+
+	req[0] = 3<<3 | modeClient
+	binary.BigEndian.PutUint64(req[40:], 0x0102030405060708)
+	resp, ok := server.response(req, time.Now())
+	if !ok {
+		t.Fatalf("expected client packet to be answered")
+	}
+	if resp[0] != 3<<3|modeServer {
+		t.Errorf("expected version 3 server mode; got %#x", resp[0])
+	}
+	if binary.BigEndian.Uint64(resp[24:]) != 0x0102030405060708 {
+		t.Errorf("expected origin time to be copied from the request transmit time")
+	}
+	if string(resp[12:16]) != "LOCL" {
+		t.Errorf("expected reference id 'LOCL'; got %q", resp[12:16])
+	}
+
+	if err := server.Close(); err != nil {
+		t.Errorf("expected err == nil; got '%s'", err.Error())
+	}
+	if err := server.Serve(); err != nil {
+		t.Errorf("expected Serve to return nil after Close; got '%s'", err.Error())
+	}
+}
+
+func TestServerKeepsServingAfterWriteError(t *testing.T) {
+	var errorLog bytes.Buffer
+	server, err := NewServer(ServerConfig{Addr: "127.0.0.1:0", ErrorLog: log.New(&errorLog, "", 0)})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer server.Close()
+
+	// первый ответ не отправляется, как будто клиент недоступен
+	write := server.write
+	var failed int32
+	server.write = func(b []byte, addr *net.UDPAddr) (int, error) {
+		if atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			return 0, errors.New("network is unreachable")
+		}
+		return write(b, addr)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve() }()
+
+	host, port, _ := splitServer(server.Addr())
+	opt := ntp.QueryOptions{Port: port, Timeout: 200 * time.Millisecond}
+	if _, err := ntp.QueryWithOptions(host, opt); err == nil {
+		t.Fatalf("expected the first query to time out, but err is nil")
+	}
+	if _, err := ntp.QueryWithOptions(host, opt); err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+
+	server.Close()
+	if err := <-served; err != nil {
+		t.Errorf("expected Serve to return nil after Close; got '%s'", err.Error())
+	}
+	if !strings.Contains(errorLog.String(), "network is unreachable") {
+		t.Errorf("expected write error in the log; got '%s'", errorLog.String())
+	}
+}
+
+func TestNewServerConfig(t *testing.T) {
+	testTable := []struct {
+		name      string
+		conf      ServerConfig
+		haveError bool
+	}{
+		{name: "defaults", conf: ServerConfig{}},
+		{name: "stratum 1 code", conf: ServerConfig{Stratum: 1, ReferenceID: "GPS"}},
+		{name: "stratum 1 long code", conf: ServerConfig{Stratum: 1, ReferenceID: "GPSCLK"}, haveError: true},
+		{name: "stratum 1 non-printable code", conf: ServerConfig{Stratum: 1, ReferenceID: "\x01"}, haveError: true},
+		{name: "stratum 3 address", conf: ServerConfig{Stratum: 3, ReferenceID: "192.168.1.1"}},
+		{name: "stratum 3 code", conf: ServerConfig{Stratum: 3, ReferenceID: "GPS"}, haveError: true},
+		{name: "stratum 16", conf: ServerConfig{Stratum: 16}, haveError: true},
+		{name: "negative dispersion", conf: ServerConfig{RootDispersion: -time.Second}, haveError: true},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			testingCase.conf.Addr = "127.0.0.1:0"
+			server, err := NewServer(testingCase.conf)
+			if testingCase.haveError {
+				if err == nil {
+					server.Close()
+					t.Errorf("expected error, but err is nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			server.Close()
+		})
+	}
+}
+
+func TestToNtpTime(t *testing.T) {
+	moment := time.Date(2021, 11, 12, 10, 0, 0, 500000000, time.UTC)
+	value := toNtpTime(moment)
+
+	sec := int64(value >> 32)
+	frac := int64(value & 0xffffffff)
+	back := ntpEpoch.Add(time.Duration(sec)*time.Second + time.Duration(frac*int64(time.Second)>>32))
+	if diff := back.Sub(moment); diff > time.Nanosecond || diff < -time.Nanosecond {
+		t.Errorf("expected %s; got %s", moment, back)
+	}
+}
@@ -1,8 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/beevik/ntp"
@@ -31,26 +42,508 @@ go vet проверяет исходный код Go и сообщает о по
 OK go vet -c=10 develop/dev01/task.go
 OK golint develop/dev02/task.go
 
-Запуск:
-go run task.go
+Запуск (серверы опрашиваются параллельно, по умолчанию - пул beevik-ntp):
+go run .
+go run . 0.pool.ntp.org 1.pool.ntp.org 2.pool.ntp.org time.google.com:123
+go run . -server time.google.com -timeout 2s -version 3 -format json
+go run . -server 127.0.0.1 -port 1123 -format unix-nanos
+
+Флаги:
+-server		NTP сервер host[:port], можно повторять или перечислять через запятую
+-timeout	таймаут запроса (5s)
+-version	версия протокола NTP: 2, 3 или 4 (4)
+-port		порт сервера, если он не указан в адресе (123)
+-format		формат вывода: text, json, rfc3339, unix-nanos (text)
+-set		скорректировать системные часы на измеренное смещение (см. clock.go)
+-dry-run	с -set - только напечатать план коррекции
+-step-threshold	с -set - смещения больше порога устанавливаются скачком, меньше - плавно (128ms)
+
+Результат печатается в STDOUT, диагностика - в STDERR.
+Ответы серверов проверяются перед использованием смещения (см. validate.go): "kiss-o'-death",
+индикатор коррекции 3, stratum > 15, нулевое время отправки, расстояние до эталона больше 1.5s.
+
+Коды завершения:
+0 - успех
+1 - прочие ошибки
+2 - некорректные флаги или аргументы
+3 - не удалось разрешить имя сервера (DNS)
+4 - таймаут запроса
+5 - сервер ответил "kiss-o'-death"
+6 - некорректный ответ сервера
+7 - серверы не согласны между собой (нет большинства)
+8 - смещение превысило -max-offset (режим -watch с -alert-exit)
+9 - недостаточно прав для изменения системных часов (-set)
+Если не ответил ни один сервер, код определяется ошибкой первого из них.
+
+Локальный SNTP сервер (см. server.go):
+go run . serve -port 1123 -stratum 1 -refid GPS -skew 2s
+
+Мониторинг дрейфа часов (см. watch.go, опрашивается первый сервер из списка):
+go run . -watch 10s -max-offset 50ms pool.ntp.org
 */
 
-func main() {
-	// настройка логгера на вывод в Stderr
-	logger := log.New(os.Stderr, "", 0)
+// defaultServers - серверы, опрашиваемые, если список не передан в аргументах
+var defaultServers = []string{
+	"0.beevik-ntp.pool.ntp.org",
+	"1.beevik-ntp.pool.ntp.org",
+	"2.beevik-ntp.pool.ntp.org",
+	"3.beevik-ntp.pool.ntp.org",
+}
+
+// ErrNoMajority - интервалы ответивших серверов не пересекаются у большинства:
+// невозможно отличить правильные часы от "falseticker"-ов
+var ErrNoMajority = errors.New("no majority of servers agree on the time")
+
+// ErrKissOfDeath - сервер ответил "kiss-o'-death" (stratum 0) и просит не опрашивать его
+var ErrKissOfDeath = errors.New("kiss of death received")
+
+// ErrInvalidResponse - ответ сервера не прошел проверку
+var ErrInvalidResponse = errors.New("invalid response")
+
+// Sample - результат опроса одного сервера
+type Sample struct {
+	Server   string
+	Response *ntp.Response
+	Err      error
+}
+
+// Consensus - итоговое смещение часов, согласованное большинством серверов
+type Consensus struct {
+	// Offset - середина интервала пересечения, добавляется к time.Now()
+	Offset time.Duration
+	// Low, High - границы доверительного интервала смещения
+	Low, High time.Duration
+	// Truechimers - серверы, интервалы которых содержат итоговый интервал
+	Truechimers []Sample
+	// Falsetickers - ответившие серверы, не попавшие в пересечение
+	Falsetickers []Sample
+	// Failed - серверы, не ответившие или вернувшие некорректный ответ
+	Failed []Sample
+}
+
+// Confidence - полуширина доверительного интервала
+func (c *Consensus) Confidence() time.Duration {
+	return (c.High - c.Low) / 2
+}
+
+// queryServers - параллельный опрос серверов. Сервер может быть задан как "host" или "host:port"
+func queryServers(servers []string, opt ntp.QueryOptions) []Sample {
+	samples := make([]Sample, len(servers))
+	var wg sync.WaitGroup
+
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			samples[i] = querySample(server, opt)
+		}(i, server)
+	}
+
+	wg.Wait()
+	return samples
+}
+
+func querySample(server string, opt ntp.QueryOptions) Sample {
+	host, port, err := splitServer(server)
+	if err != nil {
+		return Sample{Server: server, Err: err}
+	}
+	if port != 0 {
+		opt.Port = port
+	}
+
+	response, err := ntp.QueryWithOptions(host, opt)
+	if err != nil {
+		return Sample{Server: server, Err: wrapQueryError(err)}
+	}
+	if err := validateResponse(response); err != nil {
+		return Sample{Server: server, Response: response, Err: err}
+	}
+	return Sample{Server: server, Response: response}
+}
+
+// splitServer - разбор адреса вида "host[:port]"; port == 0 - порт по умолчанию
+func splitServer(server string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(server)
+	if err != nil {
+		// адрес без порта
+		return server, 0, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in server address '%s'", server)
+	}
+	return host, port, nil
+}
+
+// edge - граница интервала [offset - rootDistance, offset + rootDistance] одного сервера
+type edge struct {
+	offset time.Duration
+	// -1 - начало интервала, +1 - конец
+	kind int
+}
+
+// findConsensus - алгоритм Марзулло: ищет отрезок, который содержится в интервалах
+// наибольшего числа серверов. Если таких серверов не большинство - ErrNoMajority
+func findConsensus(samples []Sample) (*Consensus, error) {
+	result := &Consensus{}
+	valid := []Sample{}
+	for _, sample := range samples {
+		if sample.Err != nil || sample.Response == nil {
+			result.Failed = append(result.Failed, sample)
+			continue
+		}
+		valid = append(valid, sample)
+	}
+
+	if len(valid) == 0 {
+		return result, ErrNoMajority
+	}
+
+	edges := make([]edge, 0, 2*len(valid))
+	for _, sample := range valid {
+		lo, hi := sampleInterval(sample)
+		edges = append(edges, edge{offset: lo, kind: -1}, edge{offset: hi, kind: +1})
+	}
+
+	// при равных смещениях начало интервала идет раньше конца,
+	// чтобы соприкасающиеся интервалы считались пересекающимися
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].offset == edges[j].offset {
+			return edges[i].kind < edges[j].kind
+		}
+		return edges[i].offset < edges[j].offset
+	})
+
+	best, count := 0, 0
+	for i, e := range edges {
+		count -= e.kind
+		if count > best {
+			best = count
+			result.Low = e.offset
+			result.High = edges[i+1].offset
+		}
+	}
+
+	if best <= len(valid)/2 {
+		result.Falsetickers = valid
+		return result, ErrNoMajority
+	}
+
+	for _, sample := range valid {
+		lo, hi := sampleInterval(sample)
+		if lo <= result.Low && hi >= result.High {
+			result.Truechimers = append(result.Truechimers, sample)
+		} else {
+			result.Falsetickers = append(result.Falsetickers, sample)
+		}
+	}
+
+	result.Offset = result.Low + (result.High-result.Low)/2
+	return result, nil
+}
+
+func sampleInterval(sample Sample) (time.Duration, time.Duration) {
+	r := sample.Response
+	return r.ClockOffset - r.RootDistance, r.ClockOffset + r.RootDistance
+}
+
+// Коды завершения программы
+const (
+	exitOK = 0
+	// exitError - прочие ошибки
+	exitError = 1
+	// exitUsage - некорректные флаги или аргументы
+	exitUsage = 2
+	// exitDNS - не удалось разрешить имя сервера
+	exitDNS = 3
+	// exitTimeout - сервер не ответил за отведенное время
+	exitTimeout = 4
+	// exitKissOfDeath - сервер ответил "kiss-o'-death" (stratum 0)
+	exitKissOfDeath = 5
+	// exitInvalidResponse - ответ сервера не прошел проверку
+	exitInvalidResponse = 6
+	// exitNoMajority - ответившие серверы не согласны между собой
+	exitNoMajority = 7
+	// exitOffsetThreshold - в режиме -watch -alert-exit смещение превысило -max-offset
+	exitOffsetThreshold = 8
+	// exitPermission - недостаточно прав для изменения системных часов (-set)
+	exitPermission = 9
+)
+
+// Форматы вывода времени
+const (
+	formatText      = "text"
+	formatJSON      = "json"
+	formatRFC3339   = "rfc3339"
+	formatUnixNanos = "unix-nanos"
+)
+
+// serverList - список серверов, флаг можно повторять или перечислять серверы через запятую
+type serverList []string
+
+func (l *serverList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *serverList) Set(value string) error {
+	for _, server := range strings.Split(value, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			*l = append(*l, server)
+		}
+	}
+	return nil
+}
+
+// Config - конфигурация программы
+type Config struct {
+	servers   serverList
+	timeout   time.Duration
+	version   int
+	port      int
+	format    string
+	maxOffset time.Duration
+	watch     WatchConfig
+
+	set           bool
+	dryRun        bool
+	stepThreshold time.Duration
+}
+
+// queryOptions - параметры запроса к NTP серверу
+func (c *Config) queryOptions() ntp.QueryOptions {
+	return ntp.QueryOptions{Timeout: c.timeout, Version: c.version, Port: c.port}
+}
+
+// parseFlags - разбор аргументов командной строки. При -h/-help возвращается flag.ErrHelp,
+// а output содержит справку. Серверы можно передать флагом -server и/или позиционными аргументами
+func parseFlags(progname string, args []string) (config *Config, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var conf Config
+	flags.Var(&conf.servers, "server", "NTP server as host[:port]; repeatable or comma-separated")
+	flags.DurationVar(&conf.timeout, "timeout", 5*time.Second, "Query timeout")
+	flags.IntVar(&conf.version, "version", 4, "NTP protocol version (2, 3 or 4)")
+	flags.IntVar(&conf.port, "port", 123, "Server port when not given in the address")
+	flags.StringVar(&conf.format, "format", formatText, "Output format: text, json, rfc3339 or unix-nanos")
+	flags.DurationVar(&conf.watch.Interval, "watch", 0, "Poll the server with the given interval instead of a single query")
+	flags.IntVar(&conf.watch.Count, "count", 0, "Number of polls in watch mode, 0 - infinite")
+	flags.DurationVar(&conf.maxOffset, "max-offset", 0, "Alert when the clock offset exceeds this value in watch mode")
+	flags.BoolVar(&conf.watch.AlertExit, "alert-exit", false, "Exit with a non-zero code on alert instead of a warning")
+	flags.StringVar(&conf.watch.MetricsAddr, "metrics", "", "Serve Prometheus metrics on this address instead of JSON lines")
+	flags.BoolVar(&conf.set, "set", false, "Apply the measured offset to the system clock")
+	flags.BoolVar(&conf.dryRun, "dry-run", false, "With -set, only print the planned clock adjustment")
+	flags.DurationVar(&conf.stepThreshold, "step-threshold", defaultStepThreshold, "With -set, step offsets above this value, slew smaller ones")
+
+	err = flags.Parse(args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+
+	conf.servers = append(conf.servers, flags.Args()...)
+	if len(conf.servers) == 0 {
+		conf.servers = append(serverList{}, defaultServers...)
+	}
+
+	switch {
+	case conf.timeout <= 0:
+		err = fmt.Errorf("timeout must be positive")
+	case conf.version < 2 || conf.version > 4:
+		err = fmt.Errorf("invalid NTP version %d: must be 2, 3 or 4", conf.version)
+	case conf.port <= 0 || conf.port > 65535:
+		err = fmt.Errorf("invalid port %d", conf.port)
+	case conf.format != formatText && conf.format != formatJSON && conf.format != formatRFC3339 && conf.format != formatUnixNanos:
+		err = fmt.Errorf("unknown output format '%s'", conf.format)
+	case conf.watch.Interval < 0:
+		err = fmt.Errorf("watch interval must be positive")
+	case conf.set && conf.watch.Interval != 0:
+		err = fmt.Errorf("-set can not be combined with -watch")
+	case conf.dryRun && !conf.set:
+		err = fmt.Errorf("-dry-run requires -set")
+	case conf.stepThreshold < 0:
+		err = fmt.Errorf("step threshold must not be negative")
+	}
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &conf, buf.String(), nil
+}
+
+// exitCode - код завершения, соответствующий ошибке
+func exitCode(err error) int {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &dnsErr):
+		return exitDNS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return exitTimeout
+	case errors.Is(err, ErrKissOfDeath):
+		return exitKissOfDeath
+	case errors.Is(err, ErrInvalidResponse):
+		return exitInvalidResponse
+	case errors.Is(err, ErrNoMajority):
+		return exitNoMajority
+	case errors.Is(err, ErrOffsetThreshold):
+		return exitOffsetThreshold
+	case errors.Is(err, os.ErrPermission):
+		return exitPermission
+	default:
+		return exitError
+	}
+}
+
+// Result - итог однократного запроса времени
+type Result struct {
+	Time         time.Time `json:"time"`
+	UnixNanos    int64     `json:"unix_nanos"`
+	OffsetNs     int64     `json:"offset_ns"`
+	ConfidenceNs int64     `json:"confidence_ns"`
+	Servers      int       `json:"servers"`
+	Agreed       int       `json:"agreed"`
+}
+
+// writeResult - вывод результата в выбранном формате
+func writeResult(w io.Writer, result Result, format string) error {
+	var err error
+	switch format {
+	case formatJSON:
+		err = json.NewEncoder(w).Encode(result)
+	case formatRFC3339:
+		_, err = fmt.Fprintln(w, result.Time.Format(time.RFC3339Nano))
+	case formatUnixNanos:
+		_, err = fmt.Fprintln(w, result.UnixNanos)
+	default:
+		_, err = fmt.Fprintf(w, "%s (offset %s ± %s, %d of %d servers agreed)\n", result.Time.Format(time.RFC3339Nano),
+			time.Duration(result.OffsetNs), time.Duration(result.ConfidenceNs), result.Agreed, result.Servers)
+	}
+	return err
+}
 
-	// Querying the current time
-	currentTime, err := ntp.Time("0.beevik-ntp.pool.ntp.org")
+// run - точка входа в программу, возвращает код завершения
+func run(args []string, stdout, stderr io.Writer) int {
+	return runWithClock(args, stdout, stderr, systemClock{})
+}
+
+// runWithClock - run с заданными системными часами для режима -set
+func runWithClock(args []string, stdout, stderr io.Writer, clock Clock) int {
+	logger := log.New(stderr, "", 0)
+
+	if len(args) > 0 && args[0] == "serve" {
+		return serve(args[1:], logger)
+	}
+
+	conf, output, err := parseFlags("dev01", args)
+	if err == flag.ErrHelp {
+		fmt.Fprint(stderr, output)
+		return exitUsage
+	} else if err != nil {
+		fmt.Fprint(stderr, output)
+		logger.Print(err.Error())
+		return exitUsage
+	}
+
+	if conf.watch.Interval != 0 {
+		monitor := NewMonitor(conf.servers[0], conf.queryOptions(), conf.maxOffset)
+		err := watch(monitor, conf.watch, stdout, logger)
+		if err != nil {
+			logger.Print(err.Error())
+		}
+		return exitCode(err)
+	}
+
+	samples := queryServers(conf.servers, conf.queryOptions())
+	consensus, err := findConsensus(samples)
+	for _, sample := range consensus.Failed {
+		logger.Printf("%s: %s", sample.Server, sample.Err)
+	}
+	for _, sample := range consensus.Falsetickers {
+		logger.Printf("%s: falseticker, offset %s", sample.Server, sample.Response.ClockOffset)
+	}
 	if err != nil {
-		logger.Fatal(err.Error())
+		// если не ответил ни один сервер - код по первой ошибке
+		if len(consensus.Failed) == len(samples) {
+			return exitCode(consensus.Failed[0].Err)
+		}
+		logger.Print(err.Error())
+		return exitCode(err)
 	}
-	log.Printf("The current time is %s", currentTime)
 
-	// Querying time metadata
-	response, err := ntp.Query("0.beevik-ntp.pool.ntp.org")
+	if conf.set {
+		adj, err := applyAdjustment(clock, planAdjustment(consensus.Offset, conf.stepThreshold), conf.dryRun)
+		if err != nil {
+			logger.Print(err.Error())
+			return exitCode(err)
+		}
+		if err := writeAdjustment(stdout, adj, conf.format); err != nil {
+			logger.Print(err.Error())
+			return exitError
+		}
+		return exitOK
+	}
+
+	now := time.Now().Add(consensus.Offset)
+	err = writeResult(stdout, Result{
+		Time:         now,
+		UnixNanos:    now.UnixNano(),
+		OffsetNs:     int64(consensus.Offset),
+		ConfidenceNs: int64(consensus.Confidence()),
+		Servers:      len(samples),
+		Agreed:       len(consensus.Truechimers),
+	}, conf.format)
+	if err != nil {
+		logger.Print(err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// serve - запуск локального SNTP сервера с параметрами из аргументов командной строки
+func serve(args []string, logger *log.Logger) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(logger.Writer())
+	host := flags.String("host", "", "Address to listen on")
+	port := flags.Int("port", 123, "UDP port to listen on")
+	stratum := flags.Uint("stratum", 1, "Stratum to report (1..15)")
+	refID := flags.String("refid", "LOCL", "Reference ID: source code for stratum 1, IPv4 address otherwise")
+	skew := flags.Duration("skew", 0, "Fixed offset added to the served time")
+	dispersion := flags.Duration("dispersion", 0, "Root dispersion to report")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *stratum < 1 || *stratum > maxStratum {
+		logger.Printf("invalid stratum %d: must be in 1..%d", *stratum, maxStratum)
+		return exitUsage
+	}
+
+	server, err := NewServer(ServerConfig{
+		Addr:           net.JoinHostPort(*host, strconv.Itoa(*port)),
+		Stratum:        uint8(*stratum),
+		ReferenceID:    *refID,
+		Skew:           *skew,
+		RootDispersion: *dispersion,
+		ErrorLog:       logger,
+	})
 	if err != nil {
-		logger.Fatal(err.Error())
+		logger.Print(err.Error())
+		return exitError
+	}
+	defer server.Close()
+
+	logger.Printf("SNTP server is listening on %s", server.Addr())
+	if err := server.Serve(); err != nil {
+		logger.Print(err.Error())
+		return exitError
 	}
-	timeWithOffset := time.Now().Add(response.ClockOffset)
-	log.Printf("The time with metadata %s", timeWithOffset)
+	return exitOK
 }
@@ -1 +1,281 @@
 package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// startStandIn - локальный SNTP сервер на случайном порту, отвечающий временем со смещением offset
+// и заданной корневой дисперсией. Возвращает адрес вида "127.0.0.1:port"
+func startStandIn(t *testing.T, offset, dispersion time.Duration) string {
+	server, err := NewServer(ServerConfig{
+		Addr:           "127.0.0.1:0",
+		Skew:           offset,
+		RootDispersion: dispersion,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(func() { server.Close() })
+	go server.Serve()
+
+	return server.Addr()
+}
+
+func TestQueryServersConsensus(t *testing.T) {
+	servers := []string{
+		startStandIn(t, time.Second, 50*time.Millisecond),
+		startStandIn(t, time.Second+20*time.Millisecond, 50*time.Millisecond),
+		startStandIn(t, time.Second-20*time.Millisecond, 50*time.Millisecond),
+		startStandIn(t, 30*time.Second, 50*time.Millisecond),
+		"127.0.0.1:notaport",
+	}
+
+	samples := queryServers(servers, ntp.QueryOptions{Timeout: time.Second})
+	consensus, err := findConsensus(samples)
+	if err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+
+	if len(consensus.Truechimers) != 3 {
+		t.Errorf("expected 3 truechimers; got %d", len(consensus.Truechimers))
+	}
+	if len(consensus.Falsetickers) != 1 || consensus.Falsetickers[0].Server != servers[3] {
+		t.Errorf("expected falseticker '%s'; got %v", servers[3], consensus.Falsetickers)
+	}
+	if len(consensus.Failed) != 1 || consensus.Failed[0].Server != servers[4] {
+		t.Errorf("expected failed server '%s'; got %v", servers[4], consensus.Failed)
+	}
+
+	if diff := consensus.Offset - time.Second; diff > 50*time.Millisecond || diff < -50*time.Millisecond {
+		t.Errorf("expected offset near 1s; got %s", consensus.Offset)
+	}
+	if consensus.Low > consensus.Offset || consensus.High < consensus.Offset {
+		t.Errorf("offset %s is outside of interval [%s, %s]", consensus.Offset, consensus.Low, consensus.High)
+	}
+	if consensus.Confidence() <= 0 || consensus.Confidence() > 50*time.Millisecond {
+		t.Errorf("expected confidence in (0, 50ms]; got %s", consensus.Confidence())
+	}
+}
+
+func TestQueryServersNoMajority(t *testing.T) {
+	servers := []string{
+		startStandIn(t, -10*time.Second, 10*time.Millisecond),
+		startStandIn(t, 10*time.Second, 10*time.Millisecond),
+	}
+
+	samples := queryServers(servers, ntp.QueryOptions{Timeout: time.Second})
+	_, err := findConsensus(samples)
+	if !errors.Is(err, ErrNoMajority) {
+		t.Errorf("expected ErrNoMajority; got %v", err)
+	}
+}
+
+func TestFindConsensus(t *testing.T) {
+	sample := func(offset, distance time.Duration) Sample {
+		return Sample{Response: &ntp.Response{ClockOffset: offset, RootDistance: distance}}
+	}
+
+	testTable := []struct {
+		name         string
+		in           []Sample
+		low, high    time.Duration
+		truechimers  int
+		falsetickers int
+		haveError    bool
+	}{
+		{
+			name: "all servers agree",
+			in: []Sample{
+				sample(10*time.Millisecond, 10*time.Millisecond),
+				sample(15*time.Millisecond, 10*time.Millisecond),
+				sample(12*time.Millisecond, 10*time.Millisecond),
+			},
+			low: 5 * time.Millisecond, high: 20 * time.Millisecond, truechimers: 3,
+		},
+		{
+			name: "one falseticker",
+			in: []Sample{
+				sample(10*time.Millisecond, 10*time.Millisecond),
+				sample(15*time.Millisecond, 10*time.Millisecond),
+				sample(time.Second, 10*time.Millisecond),
+			},
+			low: 5 * time.Millisecond, high: 20 * time.Millisecond, truechimers: 2, falsetickers: 1,
+		},
+		{
+			name: "touching intervals",
+			in: []Sample{
+				sample(0, 10*time.Millisecond),
+				sample(20*time.Millisecond, 10*time.Millisecond),
+			},
+			low: 10 * time.Millisecond, high: 10 * time.Millisecond, truechimers: 2,
+		},
+		{
+			name: "no majority",
+			in: []Sample{
+				sample(0, time.Millisecond),
+				sample(time.Second, time.Millisecond),
+			},
+			haveError: true,
+		},
+		{
+			name:      "all servers failed",
+			in:        []Sample{{Server: "a", Err: errors.New("timeout")}},
+			haveError: true,
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			result, err := findConsensus(testingCase.in)
+			if testingCase.haveError {
+				if !errors.Is(err, ErrNoMajority) {
+					t.Errorf("expected ErrNoMajority; got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if result.Low != testingCase.low || result.High != testingCase.high {
+				t.Errorf("expected interval [%s, %s]; got [%s, %s]", testingCase.low, testingCase.high, result.Low, result.High)
+			}
+			if len(result.Truechimers) != testingCase.truechimers || len(result.Falsetickers) != testingCase.falsetickers {
+				t.Errorf("expected %d truechimers and %d falsetickers; got %d and %d", testingCase.truechimers,
+					testingCase.falsetickers, len(result.Truechimers), len(result.Falsetickers))
+			}
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	testTable := []struct {
+		name      string
+		args      []string
+		servers   []string
+		format    string
+		port      int
+		haveError bool
+	}{
+		{name: "defaults", args: []string{}, servers: defaultServers, format: formatText, port: 123},
+		{name: "positional servers", args: []string{"a", "b:1123"}, servers: []string{"a", "b:1123"}, format: formatText, port: 123},
+		{
+			name:    "server flags",
+			args:    []string{"-server", "a,b", "-server", "c", "-port", "1123", "-format", "json", "d"},
+			servers: []string{"a", "b", "c", "d"}, format: formatJSON, port: 1123,
+		},
+		{name: "unknown format", args: []string{"-format", "xml"}, haveError: true},
+		{name: "bad version", args: []string{"-version", "5"}, haveError: true},
+		{name: "bad port", args: []string{"-port", "70000"}, haveError: true},
+		{name: "bad timeout", args: []string{"-timeout", "0s"}, haveError: true},
+		{name: "unknown flag", args: []string{"-foo"}, haveError: true},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			conf, _, err := parseFlags("dev01", testingCase.args)
+			if testingCase.haveError {
+				if err == nil {
+					t.Errorf("expected error, but err is nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if strings.Join(conf.servers, " ") != strings.Join(testingCase.servers, " ") {
+				t.Errorf("expected servers %v; got %v", testingCase.servers, conf.servers)
+			}
+			if conf.format != testingCase.format || conf.port != testingCase.port {
+				t.Errorf("expected format '%s' and port %d; got '%s' and %d", testingCase.format, testingCase.port, conf.format, conf.port)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	testTable := []struct {
+		name string
+		err  error
+		out  int
+	}{
+		{name: "no error", err: nil, out: exitOK},
+		{name: "dns", err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "x.invalid"}}, out: exitDNS},
+		{name: "timeout", err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, out: exitTimeout},
+		{name: "kiss of death", err: fmt.Errorf("%w: RATE", ErrKissOfDeath), out: exitKissOfDeath},
+		{name: "invalid response", err: fmt.Errorf("%w: invalid mode in response", ErrInvalidResponse), out: exitInvalidResponse},
+		{name: "no majority", err: ErrNoMajority, out: exitNoMajority},
+		{name: "offset threshold", err: fmt.Errorf("%w: 1s > 10ms", ErrOffsetThreshold), out: exitOffsetThreshold},
+		{name: "other", err: errors.New("boom"), out: exitError},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			if result := exitCode(testingCase.err); result != testingCase.out {
+				t.Errorf("expected exit code %d; got %d", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := startStandIn(t, 2*time.Second, 0)
+	host, port, _ := splitServer(server)
+	unanswered := silentServer(t)
+
+	testTable := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout *regexp.Regexp
+	}{
+		{name: "text", args: []string{server}, code: exitOK,
+			stdout: regexp.MustCompile(`^\d{4}-\d\d-\d\dT\S+ \(offset (1\.9\d*|2(\.\d+)?)s ± \S+, 1 of 1 servers agreed\)\n$`)},
+		{name: "json", args: []string{"-format", "json", "-server", host, "-port", strconv.Itoa(port)}, code: exitOK,
+			stdout: regexp.MustCompile(`^\{"time":"\S+","unix_nanos":\d+,"offset_ns":(19|20)\d{8},"confidence_ns":\d+,"servers":1,"agreed":1\}\n$`)},
+		{name: "rfc3339", args: []string{"-format", "rfc3339", server}, code: exitOK,
+			stdout: regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d)\n$`)},
+		{name: "unix-nanos", args: []string{"-format", "unix-nanos", server}, code: exitOK,
+			stdout: regexp.MustCompile(`^\d{19}\n$`)},
+		{name: "usage", args: []string{"-format", "xml"}, code: exitUsage, stdout: regexp.MustCompile(`^$`)},
+		{name: "timeout", args: []string{"-timeout", "50ms", unanswered}, code: exitTimeout, stdout: regexp.MustCompile(`^$`)},
+		{name: "invalid address", args: []string{"127.0.0.1:notaport"}, code: exitError, stdout: regexp.MustCompile(`^$`)},
+		{name: "serve stratum 0", args: []string{"serve", "-port", "0", "-stratum", "0"}, code: exitUsage, stdout: regexp.MustCompile(`^$`)},
+		{name: "serve stratum 16", args: []string{"serve", "-port", "0", "-stratum", "16"}, code: exitUsage, stdout: regexp.MustCompile(`^$`)},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(testingCase.args, &stdout, &stderr)
+			if code != testingCase.code {
+				t.Errorf("expected exit code %d; got %d (stderr: '%s')", testingCase.code, code, stderr.String())
+			}
+			if !testingCase.stdout.MatchString(stdout.String()) {
+				t.Errorf("expected stdout matching '%s'; got '%s'", testingCase.stdout, stdout.String())
+			}
+		})
+	}
+}
+
+// silentServer - UDP сокет, который принимает запросы, но не отвечает на них
+func silentServer(t *testing.T) string {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String()
+}
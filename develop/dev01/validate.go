@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// maxRootDistance - максимально допустимое расстояние до эталона (MAXDIST, RFC 5905)
+const maxRootDistance = 1500 * time.Millisecond
+
+// Коды "kiss-o'-death" (RFC 5905, раздел 7.4), на которые клиент обязан реагировать
+const (
+	// kissRate - клиент опрашивает сервер слишком часто, нужно увеличить интервал
+	kissRate = "RATE"
+	// kissDeny - доступ запрещен, опрос сервера нужно прекратить
+	kissDeny = "DENY"
+	// kissRstr - доступ ограничен, опрос сервера нужно прекратить
+	kissRstr = "RSTR"
+)
+
+var (
+	// ErrLeapAlarm - индикатор коррекции равен 3: часы сервера не синхронизированы
+	ErrLeapAlarm = fmt.Errorf("%w: leap indicator alarm, server clock is not synchronized", ErrInvalidResponse)
+	// ErrZeroTransmitTime - сервер не заполнил время отправки ответа
+	ErrZeroTransmitTime = fmt.Errorf("%w: zero transmit time", ErrInvalidResponse)
+	// ErrInvalidStratum - stratum больше 15
+	ErrInvalidStratum = fmt.Errorf("%w: invalid stratum", ErrInvalidResponse)
+)
+
+// KissOfDeathError - ответ сервера со stratum 0 и кодом причины отказа
+type KissOfDeathError struct {
+	Code string
+}
+
+func (e *KissOfDeathError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrKissOfDeath, e.Code)
+}
+
+// Is - KissOfDeathError соответствует ErrKissOfDeath для errors.Is
+func (e *KissOfDeathError) Is(target error) bool {
+	return target == ErrKissOfDeath
+}
+
+// RateLimited - сервер просит опрашивать его реже
+func (e *KissOfDeathError) RateLimited() bool {
+	return e.Code == kissRate
+}
+
+// Denied - сервер запретил доступ, опрашивать его больше нельзя
+func (e *KissOfDeathError) Denied() bool {
+	return e.Code == kissDeny || e.Code == kissRstr
+}
+
+// RootDistanceError - расстояние до эталона слишком велико для синхронизации
+type RootDistanceError struct {
+	Distance, Max time.Duration
+}
+
+func (e *RootDistanceError) Error() string {
+	return fmt.Sprintf("%s: root distance %s exceeds %s", ErrInvalidResponse, e.Distance, e.Max)
+}
+
+// Is - RootDistanceError соответствует ErrInvalidResponse для errors.Is
+func (e *RootDistanceError) Is(target error) bool {
+	return target == ErrInvalidResponse
+}
+
+// validateResponse - проверка ответа сервера перед использованием ClockOffset
+func validateResponse(r *ntp.Response) error {
+	switch {
+	case r.Stratum == 0:
+		return &KissOfDeathError{Code: r.KissCode}
+	case r.Stratum > maxStratum:
+		return ErrInvalidStratum
+	case r.Leap == ntp.LeapNotInSync:
+		return ErrLeapAlarm
+	case r.Time.Equal(ntpEpoch):
+		return ErrZeroTransmitTime
+	case r.RootDistance > maxRootDistance:
+		return &RootDistanceError{Distance: r.RootDistance, Max: maxRootDistance}
+	}
+
+	// оставшиеся проверки библиотеки: "свежесть" времени, дисперсия, время отправки раньше времени синхронизации
+	if err := r.Validate(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidResponse, err)
+	}
+	return nil
+}
+
+// wrapQueryError - ошибки библиотеки, не являющиеся сетевыми, - это проверки содержимого ответа.
+// Нулевое время отправки библиотека отбрасывает сама, поэтому распознается по тексту ошибки
+func wrapQueryError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr):
+		return err
+	case strings.Contains(err.Error(), "invalid transmit time"):
+		return ErrZeroTransmitTime
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidResponse, err)
+	}
+}
+
+// kissCode - код "kiss-o'-death" из ошибки; пустая строка - ошибка другого типа
+func kissCode(err error) string {
+	var kod *KissOfDeathError
+	if errors.As(err, &kod) {
+		return kod.Code
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// startCrafted - UDP сервер, отвечающий корректным SNTP пакетом, измененным функцией mutate
+func startCrafted(t *testing.T, mutate func(resp []byte)) string {
+	server, err := NewServer(ServerConfig{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(func() { server.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := server.conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			resp, ok := server.response(buf[:n], time.Now())
+			if !ok {
+				continue
+			}
+			mutate(resp)
+			server.conn.WriteToUDP(resp, addr)
+		}
+	}()
+
+	return server.Addr()
+}
+
+// kissOfDeath - пакет со stratum 0 и кодом причины в поле reference id
+func kissOfDeath(code string) func([]byte) {
+	return func(resp []byte) {
+		resp[1] = 0
+		copy(resp[12:16], code)
+	}
+}
+
+func TestQuerySampleValidation(t *testing.T) {
+	testTable := []struct {
+		name   string
+		mutate func(resp []byte)
+		check  func(err error) bool
+		exit   int
+	}{
+		{
+			name:   "valid response",
+			mutate: func(resp []byte) {},
+			check:  func(err error) bool { return err == nil },
+			exit:   exitOK,
+		},
+		{
+			name:   "kiss of death RATE",
+			mutate: kissOfDeath("RATE"),
+			check: func(err error) bool {
+				var kod *KissOfDeathError
+				return errors.As(err, &kod) && kod.Code == "RATE" && kod.RateLimited() && !kod.Denied()
+			},
+			exit: exitKissOfDeath,
+		},
+		{
+			name:   "kiss of death DENY",
+			mutate: kissOfDeath("DENY"),
+			check: func(err error) bool {
+				var kod *KissOfDeathError
+				return errors.As(err, &kod) && kod.Code == "DENY" && kod.Denied()
+			},
+			exit: exitKissOfDeath,
+		},
+		{
+			name:   "kiss of death RSTR",
+			mutate: kissOfDeath("RSTR"),
+			check: func(err error) bool {
+				var kod *KissOfDeathError
+				return errors.As(err, &kod) && kod.Denied()
+			},
+			exit: exitKissOfDeath,
+		},
+		{
+			name:   "leap indicator alarm",
+			mutate: func(resp []byte) { resp[0] |= 3 << 6 },
+			check:  func(err error) bool { return errors.Is(err, ErrLeapAlarm) },
+			exit:   exitInvalidResponse,
+		},
+		{
+			name:   "stratum 16",
+			mutate: func(resp []byte) { resp[1] = 16 },
+			check:  func(err error) bool { return errors.Is(err, ErrInvalidStratum) },
+			exit:   exitInvalidResponse,
+		},
+		{
+			name:   "root distance too large",
+			mutate: func(resp []byte) { binary.BigEndian.PutUint32(resp[8:], toNtpShort(2*time.Second)) },
+			check: func(err error) bool {
+				var distErr *RootDistanceError
+				return errors.As(err, &distErr) && distErr.Distance >= 2*time.Second && distErr.Max == maxRootDistance
+			},
+			exit: exitInvalidResponse,
+		},
+		{
+			name:   "zero transmit time",
+			mutate: func(resp []byte) { binary.BigEndian.PutUint64(resp[40:], 0) },
+			check:  func(err error) bool { return errors.Is(err, ErrZeroTransmitTime) },
+			exit:   exitInvalidResponse,
+		},
+		{
+			name:   "server mode mismatch",
+			mutate: func(resp []byte) { resp[0] = 4<<3 | modeClient },
+			check:  func(err error) bool { return errors.Is(err, ErrInvalidResponse) },
+			exit:   exitInvalidResponse,
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			server := startCrafted(t, testingCase.mutate)
+			sample := querySample(server, ntp.QueryOptions{Timeout: time.Second})
+			if !testingCase.check(sample.Err) {
+				t.Errorf("unexpected error %v", sample.Err)
+			}
+			if code := exitCode(sample.Err); code != testingCase.exit {
+				t.Errorf("expected exit code %d; got %d", testingCase.exit, code)
+			}
+		})
+	}
+}
+
+func TestWatchKissOfDeath(t *testing.T) {
+	kod := func(code string) func(string, ntp.QueryOptions) Sample {
+		return func(server string, opt ntp.QueryOptions) Sample {
+			return Sample{Server: server, Err: &KissOfDeathError{Code: code}}
+		}
+	}
+
+	monitor := NewMonitor("stub", ntp.QueryOptions{}, 0)
+	monitor.query = kod("RATE")
+	var logs bytes.Buffer
+	err := watch(monitor, WatchConfig{Interval: time.Millisecond, Count: 3}, io.Discard, log.New(&logs, "", 0))
+	if err != nil {
+		t.Errorf("expected err == nil; got '%s'", err.Error())
+	}
+	for _, interval := range []string{"2ms", "4ms", "8ms"} {
+		if !strings.Contains(logs.String(), "poll interval increased to "+interval+"\n") {
+			t.Errorf("expected backoff to %s; got log:\n%s", interval, logs.String())
+		}
+	}
+
+	var out bytes.Buffer
+	monitor.query = kod("DENY")
+	err = watch(monitor, WatchConfig{Interval: time.Millisecond, Count: 3}, &out, log.New(io.Discard, "", 0))
+	if code := kissCode(err); code != "DENY" {
+		t.Errorf("expected DENY kiss of death error; got %v", err)
+	}
+	if strings.Count(out.String(), `"kiss_code":"DENY"`) != 1 {
+		t.Errorf("expected a single sample with kiss code before stopping; got '%s'", out.String())
+	}
+}
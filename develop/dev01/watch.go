@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+/*
+Режим -watch - непрерывный мониторинг смещения часов относительно NTP сервера.
+Каждый опрос печатается JSON-строкой в STDOUT, либо (с -metrics) публикуется на /metrics
+в текстовом формате Prometheus. Дрейф часов в ppm оценивается линейной регрессией смещения по времени.
+Ответ "kiss-o'-death" RATE удваивает интервал опроса, DENY и RSTR завершают мониторинг.
+
+Запуск:
+go run . -watch 10s -max-offset 50ms pool.ntp.org
+go run . -watch 30s -metrics :9123 pool.ntp.org
+go run . -watch 1s -count 60 -max-offset 10ms -alert-exit pool.ntp.org
+*/
+
+const (
+	// driftWindow - количество последних измерений, по которым оценивается дрейф
+	driftWindow = 120
+	// maxWatchInterval - предел увеличения интервала опроса после "kiss-o'-death" RATE (2^17 с, как maxpoll в NTP)
+	maxWatchInterval = (1 << 17) * time.Second
+)
+
+// ErrOffsetThreshold - смещение часов превысило допустимый порог
+var ErrOffsetThreshold = errors.New("clock offset exceeds threshold")
+
+// WatchSample - одно измерение в режиме мониторинга
+type WatchSample struct {
+	Time     time.Time `json:"time"`
+	Server   string    `json:"server"`
+	OffsetNs int64     `json:"offset_ns"`
+	RTTNs    int64     `json:"rtt_ns"`
+	Stratum  uint8     `json:"stratum"`
+	// DriftPPM - оценка дрейфа, nil пока измерений меньше двух
+	DriftPPM *float64 `json:"drift_ppm,omitempty"`
+	Alert    bool     `json:"alert,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	// KissCode - код "kiss-o'-death", если сервер отказал в обслуживании
+	KissCode string `json:"kiss_code,omitempty"`
+}
+
+// driftPoint - точка для регрессии: x - время в секундах, y - смещение в секундах
+type driftPoint struct {
+	x, y float64
+}
+
+// Monitor - накопитель измерений смещения, RTT и stratum одного сервера
+type Monitor struct {
+	server    string
+	opt       ntp.QueryOptions
+	maxOffset time.Duration
+	// query - функция опроса сервера, подменяется в тестах
+	query func(server string, opt ntp.QueryOptions) Sample
+
+	mu       sync.Mutex
+	start    time.Time
+	points   []driftPoint
+	last     *WatchSample
+	queries  int
+	failures int
+	alerts   int
+}
+
+// NewMonitor - конструктор монитора; maxOffset == 0 - без порога
+func NewMonitor(server string, opt ntp.QueryOptions, maxOffset time.Duration) *Monitor {
+	return &Monitor{
+		server:    server,
+		opt:       opt,
+		maxOffset: maxOffset,
+		query:     querySample,
+	}
+}
+
+// Poll - опрос сервера и учет результата
+func (m *Monitor) Poll(now time.Time) WatchSample {
+	sample := m.query(m.server, m.opt)
+	return m.record(now, sample)
+}
+
+func (m *Monitor) record(now time.Time, sample Sample) WatchSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queries++
+	result := WatchSample{Time: now, Server: m.server}
+	if sample.Err != nil {
+		m.failures++
+		result.Error = sample.Err.Error()
+		result.KissCode = kissCode(sample.Err)
+		return result
+	}
+
+	r := sample.Response
+	result.OffsetNs = int64(r.ClockOffset)
+	result.RTTNs = int64(r.RTT)
+	result.Stratum = r.Stratum
+
+	if m.start.IsZero() {
+		m.start = now
+	}
+	m.points = append(m.points, driftPoint{x: now.Sub(m.start).Seconds(), y: r.ClockOffset.Seconds()})
+	if len(m.points) > driftWindow {
+		m.points = m.points[len(m.points)-driftWindow:]
+	}
+	if drift, ok := driftPPM(m.points); ok {
+		result.DriftPPM = &drift
+	}
+
+	if m.maxOffset > 0 && absDuration(r.ClockOffset) > m.maxOffset {
+		m.alerts++
+		result.Alert = true
+	}
+
+	m.last = &result
+	return result
+}
+
+// driftPPM - наклон прямой y = a + b*x, найденной методом наименьших квадратов, в миллионных долях
+func driftPPM(points []driftPoint) (float64, bool) {
+	n := float64(len(points))
+	if n < 2 {
+		return 0, false
+	}
+
+	var sumX, sumY float64
+	for _, p := range points {
+		sumX += p.x
+		sumY += p.y
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX float64
+	for _, p := range points {
+		cov += (p.x - meanX) * (p.y - meanY)
+		varX += (p.x - meanX) * (p.x - meanX)
+	}
+	if varX == 0 {
+		return 0, false
+	}
+	return cov / varX * 1e6, true
+}
+
+// metric - одна метрика в текстовом формате Prometheus
+type metric struct {
+	name, help, kind string
+	value            float64
+}
+
+// WritePrometheus - последние значения в текстовом формате Prometheus
+func (m *Monitor) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := []metric{
+		{"ntp_queries_total", "Number of NTP queries sent.", "counter", float64(m.queries)},
+		{"ntp_query_errors_total", "Number of failed NTP queries.", "counter", float64(m.failures)},
+		{"ntp_offset_alerts_total", "Number of samples with the clock offset over the threshold.", "counter", float64(m.alerts)},
+	}
+
+	if m.last != nil {
+		alert := 0.0
+		if m.last.Alert {
+			alert = 1
+		}
+		metrics = append(metrics,
+			metric{"ntp_clock_offset_seconds", "Clock offset relative to the server.", "gauge", time.Duration(m.last.OffsetNs).Seconds()},
+			metric{"ntp_rtt_seconds", "Round-trip time to the server.", "gauge", time.Duration(m.last.RTTNs).Seconds()},
+			metric{"ntp_stratum", "Stratum reported by the server.", "gauge", float64(m.last.Stratum)},
+			metric{"ntp_offset_alert", "1 if the last clock offset is over the threshold.", "gauge", alert},
+		)
+		if m.last.DriftPPM != nil {
+			metrics = append(metrics, metric{"ntp_drift_ppm", "Estimated clock drift in parts per million.", "gauge", *m.last.DriftPPM})
+		}
+	}
+
+	label := fmt.Sprintf("{server=%q}", m.server)
+	for _, metric := range metrics {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s%s %g\n",
+			metric.name, metric.help, metric.name, metric.kind, metric.name, label, metric.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP - обработчик /metrics
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// WatchConfig - параметры режима мониторинга
+type WatchConfig struct {
+	Interval time.Duration
+	// Count - количество опросов, 0 - бесконечно
+	Count int
+	// MetricsAddr - адрес HTTP сервера с /metrics; пусто - JSON-строки в out
+	MetricsAddr string
+	// AlertExit - завершиться с ErrOffsetThreshold при превышении порога вместо предупреждения
+	AlertExit bool
+}
+
+// watch - цикл опроса сервера с заданным интервалом
+func watch(m *Monitor, conf WatchConfig, out io.Writer, logger *log.Logger) error {
+	if conf.Interval <= 0 {
+		return errors.New("watch interval must be positive")
+	}
+
+	encoder := json.NewEncoder(out)
+	if conf.MetricsAddr != "" {
+		listener, err := net.Listen("tcp", conf.MetricsAddr)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m)
+		httpServer := &http.Server{Handler: mux}
+		defer httpServer.Close()
+		go httpServer.Serve(listener)
+
+		logger.Printf("serving metrics on http://%s/metrics", listener.Addr())
+	}
+
+	interval := conf.Interval
+	for i := 0; conf.Count == 0 || i < conf.Count; i++ {
+		if i > 0 {
+			<-time.After(interval)
+		}
+
+		sample := m.Poll(time.Now())
+		if conf.MetricsAddr == "" {
+			if err := encoder.Encode(sample); err != nil {
+				return err
+			}
+		}
+
+		if sample.Error != "" {
+			logger.Printf("%s: %s", sample.Server, sample.Error)
+		}
+
+		// на RATE интервал опроса удваивается, на DENY и RSTR опрос прекращается
+		switch sample.KissCode {
+		case kissRate:
+			if interval *= 2; interval > maxWatchInterval {
+				interval = maxWatchInterval
+			}
+			logger.Printf("warning: %s: rate limited by server, poll interval increased to %s", sample.Server, interval)
+		case kissDeny, kissRstr:
+			return &KissOfDeathError{Code: sample.KissCode}
+		}
+
+		if sample.Alert {
+			if conf.AlertExit {
+				// порог сравнивается с модулем смещения, поэтому и в сообщении - модуль: "-3s > 1s" читалось бы как ложь
+				return fmt.Errorf("%w: |offset| %s > %s", ErrOffsetThreshold, absDuration(time.Duration(sample.OffsetNs)), m.maxOffset)
+			}
+			logger.Printf("warning: %s: clock offset %s exceeds %s", sample.Server, time.Duration(sample.OffsetNs), m.maxOffset)
+		}
+	}
+	return nil
+}
+
+// absDuration - модуль интервала
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
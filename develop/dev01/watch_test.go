@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// stubQuery - опрос, возвращающий заранее заданные смещения по очереди
+func stubQuery(offsets ...time.Duration) func(string, ntp.QueryOptions) Sample {
+	i := 0
+	return func(server string, opt ntp.QueryOptions) Sample {
+		offset := offsets[i%len(offsets)]
+		i++
+		return Sample{Server: server, Response: &ntp.Response{ClockOffset: offset, RTT: time.Millisecond, Stratum: 2}}
+	}
+}
+
+func TestDriftPPM(t *testing.T) {
+	testTable := []struct {
+		name   string
+		points []driftPoint
+		out    float64
+		ok     bool
+	}{
+		{name: "not enough points", points: []driftPoint{{0, 0}}},
+		{name: "same time", points: []driftPoint{{1, 0}, {1, 1}}},
+		{name: "constant offset", points: []driftPoint{{0, 0.5}, {10, 0.5}, {20, 0.5}}, out: 0, ok: true},
+		// смещение растет на 1 мс за 100 с = 10 ppm
+		{name: "linear drift", points: []driftPoint{{0, 0}, {100, 0.001}, {200, 0.002}}, out: 10, ok: true},
+		{name: "noisy drift", points: []driftPoint{{0, 0.0001}, {100, 0.0009}, {200, 0.0021}, {300, 0.0029}}, out: 9.6, ok: true},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			result, ok := driftPPM(testingCase.points)
+			if ok != testingCase.ok {
+				t.Fatalf("expected ok == %v; got %v", testingCase.ok, ok)
+			}
+			if math.Abs(result-testingCase.out) > 1e-6 {
+				t.Errorf("expected %g ppm; got %g", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestMonitorPoll(t *testing.T) {
+	monitor := NewMonitor("stub", ntp.QueryOptions{}, 15*time.Millisecond)
+	monitor.query = stubQuery(0, 10*time.Millisecond, 20*time.Millisecond)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+	first := monitor.Poll(start)
+	if first.DriftPPM != nil || first.Alert {
+		t.Errorf("expected no drift and no alert for the first sample; got %+v", first)
+	}
+
+	monitor.Poll(start.Add(100 * time.Second))
+	last := monitor.Poll(start.Add(200 * time.Second))
+	if last.DriftPPM == nil || math.Abs(*last.DriftPPM-100) > 1e-6 {
+		t.Errorf("expected drift 100 ppm; got %v", last.DriftPPM)
+	}
+	if !last.Alert || last.OffsetNs != int64(20*time.Millisecond) || last.Stratum != 2 {
+		t.Errorf("expected alert with offset 20ms and stratum 2; got %+v", last)
+	}
+
+	var buf bytes.Buffer
+	if err := monitor.WritePrometheus(&buf); err != nil {
+		t.Fatalf(err.Error())
+	}
+	for _, line := range []string{
+		"# TYPE ntp_clock_offset_seconds gauge",
+		`ntp_clock_offset_seconds{server="stub"} 0.02`,
+		`ntp_rtt_seconds{server="stub"} 0.001`,
+		`ntp_stratum{server="stub"} 2`,
+		`ntp_drift_ppm{server="stub"} 100`,
+		`ntp_queries_total{server="stub"} 3`,
+		`ntp_offset_alerts_total{server="stub"} 1`,
+		`ntp_offset_alert{server="stub"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expected line '%s' in metrics:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestMonitorPollError(t *testing.T) {
+	monitor := NewMonitor("stub", ntp.QueryOptions{}, 0)
+	monitor.query = func(server string, opt ntp.QueryOptions) Sample {
+		return Sample{Server: server, Err: errors.New("i/o timeout")}
+	}
+
+	sample := monitor.Poll(time.Now())
+	if sample.Error != "i/o timeout" {
+		t.Errorf("expected error 'i/o timeout'; got '%s'", sample.Error)
+	}
+
+	var buf bytes.Buffer
+	monitor.WritePrometheus(&buf)
+	if !strings.Contains(buf.String(), `ntp_query_errors_total{server="stub"} 1`) {
+		t.Errorf("expected error counter in metrics:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "ntp_clock_offset_seconds") {
+		t.Errorf("expected no offset gauge without successful samples:\n%s", buf.String())
+	}
+}
+
+func TestWatchJSONLines(t *testing.T) {
+	server := startStandIn(t, 5*time.Millisecond, 0)
+	monitor := NewMonitor(server, ntp.QueryOptions{Timeout: time.Second}, time.Second)
+
+	var out, logs bytes.Buffer
+	err := watch(monitor, WatchConfig{Interval: 10 * time.Millisecond, Count: 3}, &out, log.New(&logs, "", 0))
+	if err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 JSON lines; got %d:\n%s", len(lines), out.String())
+	}
+	var sample WatchSample
+	if err := json.Unmarshal([]byte(lines[2]), &sample); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sample.Server != server || sample.Stratum != 1 || sample.DriftPPM == nil || sample.Alert {
+		t.Errorf("unexpected sample %+v", sample)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected no warnings; got '%s'", logs.String())
+	}
+}
+
+func TestWatchAlert(t *testing.T) {
+	monitor := NewMonitor("stub", ntp.QueryOptions{}, 10*time.Millisecond)
+	monitor.query = stubQuery(time.Millisecond, 50*time.Millisecond)
+
+	var logs bytes.Buffer
+	err := watch(monitor, WatchConfig{Interval: time.Millisecond, Count: 2}, io.Discard, log.New(&logs, "", 0))
+	if err != nil {
+		t.Errorf("expected err == nil; got '%s'", err.Error())
+	}
+	if !strings.Contains(logs.String(), "warning: stub: clock offset 50ms exceeds 10ms") {
+		t.Errorf("expected warning in log; got '%s'", logs.String())
+	}
+
+	monitor.query = stubQuery(time.Millisecond, 50*time.Millisecond)
+	err = watch(monitor, WatchConfig{Interval: time.Millisecond, Count: 5, AlertExit: true}, io.Discard, log.New(io.Discard, "", 0))
+	if !errors.Is(err, ErrOffsetThreshold) {
+		t.Errorf("expected ErrOffsetThreshold; got %v", err)
+	}
+
+	// отрицательное смещение сравнивается и выводится по модулю
+	monitor.query = stubQuery(time.Millisecond, -50*time.Millisecond)
+	err = watch(monitor, WatchConfig{Interval: time.Millisecond, Count: 5, AlertExit: true}, io.Discard, log.New(io.Discard, "", 0))
+	if !errors.Is(err, ErrOffsetThreshold) || !strings.HasSuffix(err.Error(), ": |offset| 50ms > 10ms") {
+		t.Errorf("expected '|offset| 50ms > 10ms'; got %v", err)
+	}
+}
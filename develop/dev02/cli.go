@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+Командная строка: подкоманды unpack и pack обрабатывают STDIN или файлы построчно,
+каждая строка входа дает одну строку результата. "-" вместо имени файла - STDIN.
+Без флагов unpack разбирает строки так же, как unpackString; pack не принимает некорректный UTF-8.
+
+Запуск:
+echo 'a4bc2d5e' | go run . unpack
+go run . unpack -multi-digit -braces packed1.txt packed2.txt
+go run . pack plain.txt > packed.txt
+go run . unpack -check packed.txt
+
+Флаги unpack:
+-check		только проверить строки и сообщить о каждой некорректной с номером строки, без вывода результата
+-multi-digit	счетчик из нескольких цифр (UnpackOptions.MultiDigit)
+-braces		явные счетчики и группы в скобках (UnpackOptions.Braces)
+-zero-deletes	нулевой счетчик удаляет руну (UnpackOptions.ZeroDeletes); включен, как в unpackString,
+		-zero-deletes=false - нулевой счетчик считается ошибкой
+-max-output	максимальный размер распакованной строки в байтах, < 0 - без ограничения (64MiB)
+
+Коды выхода: 0 - успех, 1 - некорректные данные или ошибка чтения, 2 - ошибка в аргументах.
+*/
+
+// Коды выхода
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// stdinName - имя STDIN в сообщениях об ошибках
+const stdinName = "-"
+
+// errInvalidLines - в режиме -check найдены некорректные строки
+var errInvalidLines = errors.New("invalid lines found")
+
+const usage = `usage: dev02 unpack [-check] [-multi-digit] [-braces] [-zero-deletes=false] [-max-output N] [file ...]
+       dev02 pack [file ...]
+`
+
+// lineFunc - обработка одной строки без '\n'; name и number - для сообщений об ошибках
+type lineFunc func(name string, number int, line string) error
+
+// run - разбор подкоманды и флагов, обработка файлов; возвращает код выхода
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+
+	var process lineFunc
+	var files []string
+	switch args[0] {
+	case "unpack":
+		flags := flag.NewFlagSet("unpack", flag.ContinueOnError)
+		flags.SetOutput(stderr)
+		check := flags.Bool("check", false, "Only validate lines and report every invalid one")
+		var opts UnpackOptions
+		flags.BoolVar(&opts.MultiDigit, "multi-digit", false, "Allow multi-digit counts")
+		flags.BoolVar(&opts.Braces, "braces", false, "Allow explicit counts in braces and groups in parentheses")
+		flags.BoolVar(&opts.ZeroDeletes, "zero-deletes", legacyUnpackOptions.ZeroDeletes, "Zero count deletes the preceding rune; -zero-deletes=false makes it an error")
+		flags.Int64Var(&opts.MaxOutput, "max-output", DefaultMaxOutput, "Maximum unpacked line size in bytes, < 0 - unlimited")
+		if err := flags.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+		files = flags.Args()
+
+		if *check {
+			process = checkLine(opts, stderr)
+		} else {
+			process = unpackLine(opts, out)
+		}
+	case "pack":
+		flags := flag.NewFlagSet("pack", flag.ContinueOnError)
+		flags.SetOutput(stderr)
+		if err := flags.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+		files = flags.Args()
+		process = packLine(out)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	if len(files) == 0 {
+		files = []string{stdinName}
+	}
+	// в режиме -check файлы проверяются до конца, в остальных - до первой ошибки
+	var invalid bool
+	for _, name := range files {
+		err := processFile(name, stdin, process)
+		if errors.Is(err, errInvalidLines) {
+			invalid = true
+			continue
+		}
+		if err != nil {
+			out.Flush()
+			fmt.Fprintln(stderr, err.Error())
+			return exitError
+		}
+	}
+	if invalid {
+		return exitError
+	}
+	return exitOK
+}
+
+// processFile - построчная обработка файла или STDIN; последняя строка может не заканчиваться '\n'
+func processFile(name string, stdin io.Reader, process lineFunc) error {
+	src := stdin
+	if name != stdinName {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		src = file
+	}
+
+	// bufio.Reader вместо bufio.Scanner: длина строки не ограничена размером буфера
+	reader := bufio.NewReader(src)
+	var invalid bool
+	for number := 1; ; number++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+
+		procErr := process(name, number, strings.TrimSuffix(line, "\n"))
+		if errors.Is(procErr, errInvalidLines) {
+			invalid = true
+		} else if procErr != nil {
+			return procErr
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	if invalid {
+		return errInvalidLines
+	}
+	return nil
+}
+
+// unpackLine - распаковка строки в out; первая некорректная строка прерывает обработку
+func unpackLine(opts UnpackOptions, out io.Writer) lineFunc {
+	return func(name string, number int, line string) error {
+		unpacked, err := unpackWithOptions(line, opts)
+		if err != nil {
+			return lineError(name, number, line, err)
+		}
+		_, err = fmt.Fprintln(out, unpacked)
+		return err
+	}
+}
+
+// checkLine - проверка строки без вывода результата; о каждой некорректной строке сообщается в report
+func checkLine(opts UnpackOptions, report io.Writer) lineFunc {
+	return func(name string, number int, line string) error {
+		_, err := io.Copy(io.Discard, NewUnpackReader(strings.NewReader(line), opts))
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintln(report, lineError(name, number, line, err).Error())
+		return errInvalidLines
+	}
+}
+
+// packLine - упаковка строки в out
+func packLine(out io.Writer) lineFunc {
+	return func(name string, number int, line string) error {
+		packed, err := packString(line)
+		if err != nil {
+			return lineError(name, number, line, err)
+		}
+		_, err = fmt.Fprintln(out, packed)
+		return err
+	}
+}
+
+// lineError - ошибка с именем файла и номером строки; для ошибки разбора добавляется строка со знаком '^'
+func lineError(name string, number int, line string, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%s:%d:%d: %w\n%s", name, number, parseErr.Pos+1, err, parseErr.Diagnostic(line))
+	}
+	return fmt.Errorf("%s:%d: %w", name, number, err)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	testTable := []struct {
+		name   string
+		args   []string
+		in     string
+		out    string
+		errOut []string
+		code   int
+	}{
+		{name: "no command", code: exitUsage, errOut: []string{"usage: dev02"}},
+		{name: "unknown command", args: []string{"zip"}, code: exitUsage, errOut: []string{`unknown command "zip"`}},
+		{name: "unknown flag", args: []string{"pack", "-braces"}, code: exitUsage},
+		{name: "unpack lines", args: []string{"unpack"}, in: "a4bc2d5e\nabcd\n\nqwe\\45\n", out: "aaaabccddddde\nabcd\n\nqwe44444\n"},
+		{name: "last line without newline", args: []string{"unpack"}, in: "a2\nb3", out: "aa\nbbb\n"},
+		{name: "unpack with grammar flags", args: []string{"unpack", "-multi-digit", "-braces"}, in: "(ab){2}c10\n", out: "ababcccccccccc\n"},
+		{name: "stdin as dash", args: []string{"unpack", "-"}, in: "x3\n", out: "xxx\n"},
+		{
+			name: "unpack stops at first invalid line", args: []string{"unpack"}, in: "a2\n45\nb2\n",
+			out: "aa\n", code: exitError, errOut: []string{"-:2:1: некорректная строка", "45\n^ digit without a preceding rune"},
+		},
+		{name: "unpack limit", args: []string{"unpack", "-max-output", "3"}, in: "a9\n", code: exitError, errOut: []string{"-:1: ", "output size"}},
+		{name: "pack lines", args: []string{"pack"}, in: "aaaabccddddde\nqwe45\n\n", out: "a4bccd5e\nqwe\\4\\5\n\n"},
+		{
+			name: "check reports every invalid line", args: []string{"unpack", "--check"}, in: "a4\nq\\we\nok\nabc\\\n",
+			code: exitError, errOut: []string{"-:2:3: ", "invalid escape target", "-:4:4: ", "dangling escape"},
+		},
+		{name: "check valid input", args: []string{"unpack", "-check"}, in: "a4\nb\n"},
+		// по умолчанию грамматика совпадает с unpackString: нулевой счетчик удаляет руну
+		{name: "zero count deletes like unpackString", args: []string{"unpack"}, in: "a0b\n", out: "b\n"},
+		{
+			name: "strict zero count", args: []string{"unpack", "-zero-deletes=false"}, in: "a0b\n",
+			code: exitError, errOut: []string{"-:1:2: ", "zero count"},
+		},
+		{name: "pack invalid utf-8", args: []string{"pack"}, in: "ok\nab\xffc\n", out: "ok\n", code: exitError, errOut: []string{"-:2: invalid UTF-8 at byte 2"}},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(testingCase.args, strings.NewReader(testingCase.in), &stdout, &stderr)
+			if code != testingCase.code {
+				t.Errorf("expected exit code %d; got %d, stderr: %s", testingCase.code, code, stderr.String())
+			}
+			if stdout.String() != testingCase.out {
+				t.Errorf("expected output %q; got %q", testingCase.out, stdout.String())
+			}
+			for _, fragment := range testingCase.errOut {
+				if !strings.Contains(stderr.String(), fragment) {
+					t.Errorf("expected %q in stderr; got %q", fragment, stderr.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	os.WriteFile(first, []byte("a2\n\\3\\\\\n"), 0o644)
+	os.WriteFile(second, []byte("b3\n5\n"), 0o644)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"unpack", first, "-", second}, strings.NewReader("c2\n"), &stdout, &stderr)
+	if code != exitError {
+		t.Errorf("expected exit code %d; got %d", exitError, code)
+	}
+	if expected := "aa\n3\\\ncc\nbbb\n"; stdout.String() != expected {
+		t.Errorf("expected output %q; got %q", expected, stdout.String())
+	}
+	if !strings.HasPrefix(stderr.String(), second+":2:1: ") {
+		t.Errorf("expected error in %s line 2; got %q", second, stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"unpack", "-check", second, first, second}, nil, &stdout, &stderr)
+	if code != exitError || strings.Count(stderr.String(), second+":2:1: ") != 2 || strings.Contains(stderr.String(), first) {
+		t.Errorf("expected two reports for %s; got code %d, stderr %q", second, code, stderr.String())
+	}
+
+	stderr.Reset()
+	code = run([]string{"pack", filepath.Join(dir, "missing.txt")}, nil, &stdout, &stderr)
+	if code != exitError || !strings.Contains(stderr.String(), "missing.txt") {
+		t.Errorf("expected open error; got code %d, stderr %q", code, stderr.String())
+	}
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"math"
+	"os"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 /*
@@ -21,6 +27,27 @@ import (
 
 В случае если была передана некорректная строка функция должна возвращать ошибку. Написать unit-тесты.
 
+Обратная функция packString возвращает кратчайшую упакованную запись строки, для любой строки
+UTF-8 unpackString(packString(s)) == s; некорректный UTF-8 - ошибка ErrInvalidUTF8:
+	- "aaaabccddddde" => "a4bccd5e"
+	- "qwe45" => "qwe\4\5"
+	- "a" * 12 => "a9a3"
+
+Расширенная грамматика (unpackWithOptions, включается полями UnpackOptions):
+	- MultiDigit: "a12b" => "aaaaaaaaaaaab"
+	- Braces: "a{3}1" - ошибка, "a{12}", "(ab){3}" => "ababab", "((ab)2c){2}" => "ababcababc"
+	- ZeroDeletes: "ab0c" => "ac", "(ab){0}c" => "c"; без него нулевой счетчик - ошибка
+
+Потоковая распаковка: NewUnpackReader(r, opts) возвращает io.Reader, разбирающий данные по мере чтения.
+Размер результата (MaxOutput), коэффициент расширения (MaxRatio) и вложенность групп (MaxDepth)
+ограничены для защиты от "бомб" вида "((((a9)9)9)9)" и "((((...": ошибки - *ParseError и *LimitError со смещением в байтах.
+
+*ParseError содержит позицию, руну и причину ошибки (errors.As), экранировать можно только цифры, '\'
+и скобки в грамматике Braces:
+	- "45" => цифра без предшествующей руны, позиция 0
+	- "qwe\" => '\' в конце строки
+	- "q\we" => экранирована обычная руна
+
 Функция должна проходить все тесты. Код должен проходить проверки go vet и golint.
 
 OK go vet -c=10 task.go
@@ -33,62 +60,545 @@ OK go test -run ''		(coverage: 83.3%)
 
 запуск из консоли теста: go test -run ''
 
-Запуск (строка для распаковки захардкожена в main):
-go run task.go
+Запуск (подкоманды и флаги - в cli.go):
+echo 'a4bc2d5e' | go run . unpack
 */
 
 func main() {
-	str := "a4bc2d5e"
-	unpacked, err := unpackString(str)
-	if err != nil {
-		log.Fatalf(err.Error())
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// errIncorrectString - ошибка разбора упакованной строки
+var errIncorrectString = errors.New("некорректная строка")
+
+// ErrInvalidUTF8 - в упаковываемой строке есть байты, не образующие руну UTF-8: packString их не принимает,
+// иначе они молча заменились бы на U+FFFD и распакованная строка отличалась бы от исходной
+var ErrInvalidUTF8 = errors.New("invalid UTF-8")
+
+// ErrLimitExceeded - распакованные данные превысили ограничение размера или коэффициента расширения
+var ErrLimitExceeded = errors.New("unpack limit exceeded")
+
+// Ограничения распаковки по умолчанию
+const (
+	// DefaultMaxOutput - максимальный размер распакованных данных в байтах
+	DefaultMaxOutput = 64 << 20
+	// DefaultMaxRatio - максимальное отношение размера распакованных данных к прочитанным
+	DefaultMaxRatio = 1 << 16
+	// DefaultMaxDepth - максимальная вложенность групп
+	DefaultMaxDepth = 100
+)
+
+// UnpackOptions - настройки грамматики и ограничений распаковки. Нулевое значение - одна цифра
+// в счетчике, без скобок, нулевой счетчик - ошибка, ограничения по умолчанию
+type UnpackOptions struct {
+	// MultiDigit - счетчик из нескольких цифр: "a12" => 12 рун 'a'
+	MultiDigit bool
+	// Braces - явный счетчик в фигурных скобках "a{12}b" и группы в круглых скобках "(ab){3}" => "ababab".
+	// Символы '(', ')', '{', '}' становятся служебными и экранируются '\'
+	Braces bool
+	// ZeroDeletes - нулевой счетчик удаляет предыдущую руну или группу: "ab0c" => "ac"
+	ZeroDeletes bool
+	// MaxOutput - максимальный размер результата в байтах; 0 - DefaultMaxOutput, < 0 - без ограничения
+	MaxOutput int64
+	// MaxRatio - максимальное отношение размера результата к размеру прочитанных данных;
+	// 0 - DefaultMaxRatio, < 0 - без ограничения
+	MaxRatio int64
+	// MaxDepth - максимальная вложенность групп; 0 и < 0 - DefaultMaxDepth. Отключить ограничение нельзя:
+	// группы разбираются рекурсивно, и строка из миллионов '(' исчерпала бы стек горутины
+	MaxDepth int
+}
+
+// legacyUnpackOptions - грамматика unpackString: одна цифра, нулевой счетчик удаляет руну
+var legacyUnpackOptions = UnpackOptions{ZeroDeletes: true}
+
+// ParseReason - причина ошибки разбора
+type ParseReason int
+
+// Причины ошибок разбора
+const (
+	// ReasonLeadingDigit - счетчик без предшествующей руны: "45", "a45"
+	ReasonLeadingDigit ParseReason = iota + 1
+	// ReasonDanglingEscape - '\' в конце строки
+	ReasonDanglingEscape
+	// ReasonInvalidEscape - экранирована руна, которая не является служебной: "\a"
+	ReasonInvalidEscape
+	// ReasonOverflow - счетчик не помещается в int
+	ReasonOverflow
+	// ReasonZeroCount - нулевой счетчик без UnpackOptions.ZeroDeletes
+	ReasonZeroCount
+	// ReasonUnexpectedRune - служебная руна не на своем месте: "a}", "ab)", "{3}"
+	ReasonUnexpectedRune
+	// ReasonInvalidCount - в фигурных скобках не число: "a{}", "a{x}"
+	ReasonInvalidCount
+	// ReasonUnclosedCount - нет закрывающей '}'
+	ReasonUnclosedCount
+	// ReasonUnclosedGroup - нет закрывающей ')'
+	ReasonUnclosedGroup
+)
+
+func (r ParseReason) String() string {
+	switch r {
+	case ReasonLeadingDigit:
+		return "digit without a preceding rune"
+	case ReasonDanglingEscape:
+		return "dangling escape"
+	case ReasonInvalidEscape:
+		return "invalid escape target"
+	case ReasonOverflow:
+		return "count overflow"
+	case ReasonZeroCount:
+		return "zero count"
+	case ReasonUnexpectedRune:
+		return "unexpected rune"
+	case ReasonInvalidCount:
+		return "invalid count"
+	case ReasonUnclosedCount:
+		return "unclosed count"
+	case ReasonUnclosedGroup:
+		return "unclosed group"
+	default:
+		return "unknown reason"
 	}
-	log.Printf("Распакованная строка: '%s'", unpacked)
+}
+
+// ParseError - некорректные входные данные
+type ParseError struct {
+	// Offset - смещение некорректного фрагмента в байтах от начала входных данных
+	Offset int64
+	// Pos - позиция некорректного фрагмента в рунах, с нуля
+	Pos int64
+	// Rune - руна, на которой обнаружена ошибка
+	Rune rune
+	// Reason - причина ошибки
+	Reason ParseReason
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s %q at position %d", errIncorrectString, e.Reason, e.Rune, e.Pos)
+}
+
+func (e *ParseError) Unwrap() error {
+	return errIncorrectString
+}
+
+// Diagnostic - строка с входными данными и знаком '^' под некорректной руной
+func (e *ParseError) Diagnostic(input string) string {
+	var caret strings.Builder
+	var pos int64
+	for _, symbol := range input {
+		if pos == e.Pos {
+			break
+		}
+		// табуляция сохраняется, чтобы знак '^' оказался под нужной руной
+		if symbol == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+		pos++
+	}
+	return fmt.Sprintf("%s\n%s^ %s", input, caret.String(), e.Reason)
+}
+
+// LimitError - превышено ограничение распаковки (защита от "бомб" вида "((a9)9)9...")
+type LimitError struct {
+	// Offset - смещение в байтах конца элемента, на котором превышено ограничение
+	Offset int64
+	// Limit - "output size", "expansion ratio", "group size" или "group depth"
+	Limit string
+	// Max - значение ограничения
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s over %d at byte %d", ErrLimitExceeded, e.Limit, e.Max, e.Offset)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
 }
 
 func unpackString(str string) (string, error) {
-	var unpackedStr strings.Builder
-	var lastRune rune
-	var isEscapeSymbol bool
-
-	for _, symbol := range str {
-		switch {
-		case isEscapeSymbol:
-			{
-				isEscapeSymbol = false
-				lastRune = symbol
-			}
-		case symbol <= '9' && symbol >= '0':
-			{
-				if lastRune != 0 {
-					// поскольку в талблице ASCI/UTF-8 каждый символ имеет код, то для перевода из числовой руны в int
-					// достаточно вычесть из кода этой руны код руны '0'
-					iterationCount := int(symbol - '0')
-					for i := 0; i < iterationCount; i++ {
-						unpackedStr.WriteRune(lastRune)
-					}
-					lastRune = 0
-				} else {
-					return "", fmt.Errorf("некорректная строка")
+	return unpackWithOptions(str, legacyUnpackOptions)
+}
+
+// unpackWithOptions - распаковка строки в выбранной грамматике
+func unpackWithOptions(str string, opts UnpackOptions) (string, error) {
+	unpacked, err := io.ReadAll(NewUnpackReader(strings.NewReader(str), opts))
+	if err != nil {
+		return "", err
+	}
+	return string(unpacked), nil
+}
+
+// UnpackReader - потоковая распаковка: читает упакованные данные из источника по мере чтения результата
+type UnpackReader struct {
+	u unpacker
+	// atom, left - текущий элемент и сколько раз его еще нужно вывести
+	atom string
+	left int
+	// pending - невыведенная часть текущего повтора atom
+	pending string
+	err     error
+}
+
+// NewUnpackReader - конструктор потокового распаковщика
+func NewUnpackReader(r io.Reader, opts UnpackOptions) *UnpackReader {
+	if opts.MaxOutput == 0 {
+		opts.MaxOutput = DefaultMaxOutput
+	}
+	if opts.MaxRatio == 0 {
+		opts.MaxRatio = DefaultMaxRatio
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+
+	src, ok := r.(io.RuneScanner)
+	if !ok {
+		src = bufio.NewReader(r)
+	}
+	return &UnpackReader{u: unpacker{src: src, opts: opts}}
+}
+
+// Read - реализация io.Reader. Повторы разворачиваются лениво, без выделения памяти под весь результат
+func (r *UnpackReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if r.pending == "" {
+			if r.left == 0 {
+				if r.err != nil {
+					break
 				}
+				r.atom, r.left, r.err = r.u.next()
+				continue
 			}
-		case symbol == '\\':
-			{
-				isEscapeSymbol = true
-				if lastRune != 0 {
-					unpackedStr.WriteRune(lastRune)
+			r.pending = r.atom
+			r.left--
+		}
+
+		copied := copy(p[n:], r.pending)
+		r.pending = r.pending[copied:]
+		n += copied
+	}
+
+	if n == 0 && r.err != nil {
+		return 0, r.err
+	}
+	return n, nil
+}
+
+// unpacker - разбор упакованных данных рекурсивным спуском:
+//
+//	sequence = { element }
+//	element  = atom [ count ]
+//	atom     = руна | '\' руна | '(' sequence ')'
+//	count    = цифра | цифры | '{' цифры '}'
+type unpacker struct {
+	src  io.RuneScanner
+	opts UnpackOptions
+	// offset, pos - количество прочитанных байт и рун
+	offset int64
+	pos    int64
+	// lastSize - длина последней прочитанной руны в байтах, для unreadRune
+	lastSize int
+	// produced - размер уже принятых на вывод данных
+	produced int64
+}
+
+// Результат разбора атома
+const (
+	atomValue = iota
+	atomEOF
+	atomGroupEnd
+)
+
+func isDigit(symbol rune) bool {
+	return symbol <= '9' && symbol >= '0'
+}
+
+// readRune - чтение руны с учетом смещения; ok == false - конец данных
+func (u *unpacker) readRune() (rune, bool, error) {
+	symbol, size, err := u.src.ReadRune()
+	if err == io.EOF {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	u.offset += int64(size)
+	u.pos++
+	u.lastSize = size
+	return symbol, true, nil
+}
+
+// unreadRune - возврат последней прочитанной руны
+func (u *unpacker) unreadRune() {
+	u.src.UnreadRune()
+	u.offset -= int64(u.lastSize)
+	u.pos--
+}
+
+// parseError - ошибка на руне symbol, начинающейся со смещения offset и позиции pos
+func (u *unpacker) parseError(reason ParseReason, symbol rune, offset, pos int64) *ParseError {
+	return &ParseError{Offset: offset, Pos: pos, Rune: symbol, Reason: reason}
+}
+
+// isEscapable - руны, которые можно экранировать: цифры, '\' и скобки в грамматике Braces
+func (u *unpacker) isEscapable(symbol rune) bool {
+	switch {
+	case isDigit(symbol), symbol == '\\':
+		return true
+	case u.opts.Braces:
+		return symbol == '(' || symbol == ')' || symbol == '{' || symbol == '}'
+	default:
+		return false
+	}
+}
+
+// next - следующий элемент верхнего уровня и число его повторов; io.EOF - данные закончились
+func (u *unpacker) next() (string, int, error) {
+	for {
+		atom, kind, err := u.atom(0)
+		if err != nil {
+			return "", 0, err
+		}
+		if kind == atomEOF {
+			return "", 0, io.EOF
+		}
+
+		count, err := u.count()
+		if err != nil {
+			return "", 0, err
+		}
+		size, err := u.reserve(int64(len(atom)), count, 0)
+		if err != nil {
+			return "", 0, err
+		}
+		u.produced += size
+
+		if size != 0 {
+			return atom, count, nil
+		}
+	}
+}
+
+// atom - разбор атома: руны, экранированной руны или группы
+func (u *unpacker) atom(depth int) (string, int, error) {
+	start, startPos := u.offset, u.pos
+	symbol, ok, err := u.readRune()
+	if err != nil || !ok {
+		return "", atomEOF, err
+	}
+
+	switch {
+	case u.opts.Braces && symbol == ')':
+		if depth == 0 {
+			return "", 0, u.parseError(ReasonUnexpectedRune, symbol, start, startPos)
+		}
+		return "", atomGroupEnd, nil
+	case u.opts.Braces && symbol == '(':
+		group, err := u.group(depth+1, start, startPos)
+		return group, atomValue, err
+	case u.opts.Braces && (symbol == '{' || symbol == '}'):
+		return "", 0, u.parseError(ReasonUnexpectedRune, symbol, start, startPos)
+	case isDigit(symbol):
+		return "", 0, u.parseError(ReasonLeadingDigit, symbol, start, startPos)
+	case symbol == '\\':
+		escapeOffset, escapePos := u.offset, u.pos
+		symbol, ok, err = u.readRune()
+		if err != nil {
+			return "", 0, err
+		}
+		if !ok {
+			return "", 0, u.parseError(ReasonDanglingEscape, '\\', start, startPos)
+		}
+		if !u.isEscapable(symbol) {
+			return "", 0, u.parseError(ReasonInvalidEscape, symbol, escapeOffset, escapePos)
+		}
+	}
+	return string(symbol), atomValue, nil
+}
+
+// group - разбор содержимого группы до закрывающей скобки; start, startPos - положение открывающей скобки
+func (u *unpacker) group(depth int, start, startPos int64) (string, error) {
+	if depth > u.opts.MaxDepth {
+		return "", &LimitError{Offset: u.offset, Limit: "group depth", Max: int64(u.opts.MaxDepth)}
+	}
+
+	var content strings.Builder
+	for {
+		atom, kind, err := u.atom(depth)
+		if err != nil {
+			return "", err
+		}
+		switch kind {
+		case atomEOF:
+			return "", u.parseError(ReasonUnclosedGroup, '(', start, startPos)
+		case atomGroupEnd:
+			return content.String(), nil
+		}
+
+		count, err := u.count()
+		if err != nil {
+			return "", err
+		}
+		size, err := u.reserve(int64(len(atom)), count, int64(content.Len()))
+		if err != nil {
+			return "", err
+		}
+		// группа собирается в памяти, поэтому ее размер ограничен даже без ограничения результата
+		maxGroup := u.opts.MaxOutput
+		if maxGroup < 0 {
+			maxGroup = DefaultMaxOutput
+		}
+		if int64(content.Len())+size > maxGroup {
+			return "", &LimitError{Offset: u.offset, Limit: "group size", Max: maxGroup}
+		}
+		content.WriteString(strings.Repeat(atom, count))
+	}
+}
+
+// count - разбор счетчика после атома; без счетчика - 1
+func (u *unpacker) count() (int, error) {
+	start, startPos := u.offset, u.pos
+	first, ok, err := u.readRune()
+	if err != nil || !ok {
+		return 1, err
+	}
+
+	var digits strings.Builder
+	switch {
+	case isDigit(first):
+		digits.WriteRune(first)
+		for u.opts.MultiDigit {
+			symbol, ok, err := u.readRune()
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				break
+			}
+			if !isDigit(symbol) {
+				u.unreadRune()
+				break
+			}
+			digits.WriteRune(symbol)
+		}
+	case u.opts.Braces && first == '{':
+		for {
+			offset, pos := u.offset, u.pos
+			symbol, ok, err := u.readRune()
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				return 0, u.parseError(ReasonUnclosedCount, first, start, startPos)
+			}
+			if symbol == '}' {
+				if digits.Len() == 0 {
+					return 0, u.parseError(ReasonInvalidCount, symbol, offset, pos)
 				}
+				break
+			}
+			if !isDigit(symbol) {
+				return 0, u.parseError(ReasonInvalidCount, symbol, offset, pos)
+			}
+			digits.WriteRune(symbol)
+		}
+	default:
+		u.unreadRune()
+		return 1, nil
+	}
+
+	count, err := strconv.Atoi(digits.String())
+	if err != nil {
+		return 0, u.parseError(ReasonOverflow, first, start, startPos)
+	}
+	if count == 0 && !u.opts.ZeroDeletes {
+		return 0, u.parseError(ReasonZeroCount, first, start, startPos)
+	}
+	return count, nil
+}
+
+// reserve - проверка ограничений перед выводом atomLen*count байт сверх уже накопленных extra байт.
+// Возвращает размер вывода atomLen*count
+func (u *unpacker) reserve(atomLen int64, count int, extra int64) (int64, error) {
+	if atomLen == 0 || count == 0 {
+		return 0, nil
+	}
+
+	maxOutput := u.opts.MaxOutput
+	if maxOutput < 0 {
+		maxOutput = math.MaxInt64
+	}
+	// проверка до умножения, чтобы избежать переполнения
+	if int64(count) > (maxOutput-u.produced-extra)/atomLen {
+		return 0, &LimitError{Offset: u.offset, Limit: "output size", Max: maxOutput}
+	}
+
+	size := atomLen * int64(count)
+	total := u.produced + extra + size
+	// total > MaxRatio*offset без переполнения
+	if u.opts.MaxRatio > 0 && (total-1)/u.offset >= u.opts.MaxRatio {
+		return 0, &LimitError{Offset: u.offset, Limit: "expansion ratio", Max: u.opts.MaxRatio}
+	}
+	return size, nil
+}
+
+// packString - обратная к unpackString операция: кратчайшая запись строки.
+// Цифры и '\\' экранируются, серии длиннее 9 разбиваются ("a"*12 => "a9a3"),
+// серия записывается счетчиком, только если это короче повторения руны ("aa" => "aa", "aaa" => "a3").
+// Строка с некорректным UTF-8 - ошибка ErrInvalidUTF8 со смещением первого такого байта
+func packString(str string) (string, error) {
+	if !utf8.ValidString(str) {
+		return "", fmt.Errorf("%w at byte %d", ErrInvalidUTF8, invalidUTF8Offset(str))
+	}
+
+	var packedStr strings.Builder
+	runes := []rune(str)
+
+	for i := 0; i < len(runes); {
+		// длина серии одинаковых рун
+		runLen := 1
+		for i+runLen < len(runes) && runes[i+runLen] == runes[i] {
+			runLen++
+		}
+
+		// symbolLen - длина записи руны в рунах: 2 для экранированной
+		symbol, symbolLen := string(runes[i]), 1
+		if runes[i] == '\\' || (runes[i] >= '0' && runes[i] <= '9') {
+			symbol, symbolLen = "\\"+symbol, 2
+		}
+
+		for left := runLen; left > 0; {
+			count := left
+			if count > 9 {
+				count = 9
 			}
-		default:
-			if lastRune != 0 {
-				unpackedStr.WriteRune(lastRune)
+			// руна со счетчиком занимает на одну руну больше, чем сама руна
+			if count*symbolLen <= symbolLen+1 {
+				packedStr.WriteString(strings.Repeat(symbol, count))
+			} else {
+				packedStr.WriteString(symbol + strconv.Itoa(count))
 			}
-			lastRune = symbol
+			left -= count
 		}
+
+		i += runLen
 	}
 
-	if lastRune != 0 {
-		unpackedStr.WriteRune(lastRune)
+	return packedStr.String(), nil
+}
+
+// invalidUTF8Offset - смещение первого байта, не образующего руну UTF-8
+func invalidUTF8Offset(str string) int {
+	for offset, symbol := range str {
+		if symbol == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(str[offset:]); size == 1 {
+				return offset
+			}
+		}
 	}
-	return unpackedStr.String(), nil
+	return len(str)
 }
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"testing/quick"
+	"unicode/utf8"
 )
 
 func TestUnpackString(t *testing.T) {
@@ -33,8 +41,8 @@ func TestUnpackString(t *testing.T) {
 				}
 			} else {
 				if err != nil {
-					if err.Error() != "некорректная строка" {
-						t.Errorf("expected err.Error() == 'некорректная строка'; got '%s'", err.Error())
+					if !errors.Is(err, errIncorrectString) {
+						t.Errorf("expected 'некорректная строка' error; got '%s'", err.Error())
 					}
 				} else {
 					t.Errorf("expected err.Error() == 'некорректная строка', but err is nil")
@@ -43,3 +51,388 @@ func TestUnpackString(t *testing.T) {
 		})
 	}
 }
+
+func TestPackString(t *testing.T) {
+	testTable := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{name: "simple pack", in: "aaaabccddddde", out: "a4bccd5e"},
+		{name: "no pack operations", in: "abcd", out: "abcd"},
+		{name: "empty string", in: "", out: ""},
+		{name: "two runes are not packed", in: "aa", out: "aa"},
+		{name: "run longer than 9", in: strings.Repeat("a", 12), out: "a9a3"},
+		{name: "run of 10", in: strings.Repeat("a", 10), out: "a9a"},
+		{name: "run of 11", in: strings.Repeat("a", 11), out: "a9aa"},
+		{name: "run of 18", in: strings.Repeat("a", 18), out: "a9a9"},
+		{name: "escape digits", in: "qwe45", out: "qwe\\4\\5"},
+		{name: "escaped run", in: "qwe44444", out: "qwe\\45"},
+		{name: "two escaped runes are packed", in: "44", out: "\\42"},
+		{name: "escape backslashes", in: "qwe\\\\\\\\\\", out: "qwe\\\\5"},
+		{name: "unicode", in: "ыыыы日日本", out: "ы4日日本"},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			result, err := packString(testingCase.in)
+			if err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if result != testingCase.out {
+				t.Errorf("expected result '%s'; got '%s'", testingCase.out, result)
+			}
+		})
+	}
+}
+
+// packedAlphabet - алфавит с цифрами, '\' и многобайтовыми рунами для генерации строк с длинными сериями
+var packedAlphabet = []rune{'a', 'b', '0', '9', '\\', 'ы', '日', 0, '😀'}
+
+// runsString - строка из случайных серий для property-based тестов
+type runsString string
+
+func (runsString) Generate(rand *rand.Rand, size int) reflect.Value {
+	var str strings.Builder
+	for i := rand.Intn(size + 1); i > 0; i-- {
+		symbol := packedAlphabet[rand.Intn(len(packedAlphabet))]
+		str.WriteString(strings.Repeat(string(symbol), 1+rand.Intn(25)))
+	}
+	return reflect.ValueOf(runsString(str.String()))
+}
+
+func TestPackStringRoundTrip(t *testing.T) {
+	roundTrip := func(str string) bool {
+		packed, err := packString(str)
+		if err != nil {
+			return false
+		}
+		unpacked, err := unpackString(packed)
+		return err == nil && unpacked == str
+	}
+
+	// некорректный UTF-8 не заменяется на U+FFFD, а отклоняется
+	for _, str := range []string{"\xff", "ab\xc3", "日\x80日", "latin-1 caf\xe9"} {
+		if packed, err := packString(str); !errors.Is(err, ErrInvalidUTF8) {
+			t.Errorf("expected ErrInvalidUTF8 for %q; got %q, %v", str, packed, err)
+		}
+	}
+
+	// произвольные строки Unicode
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 5000}); err != nil {
+		t.Error(err)
+	}
+
+	// строки из длинных серий цифр, '\' и многобайтовых рун
+	err := quick.Check(func(str runsString) bool { return roundTrip(string(str)) }, &quick.Config{MaxCount: 5000})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPackStringIsShortest(t *testing.T) {
+	// запись не длиннее самой строки с экранированием и не длиннее исходной упаковки
+	err := quick.Check(func(str runsString) bool {
+		packedStr, _ := packString(string(str))
+		packed := []rune(packedStr)
+		escaped := 0
+		for _, symbol := range string(str) {
+			escaped++
+			if symbol == '\\' || (symbol >= '0' && symbol <= '9') {
+				escaped++
+			}
+		}
+		return len(packed) <= escaped
+	}, &quick.Config{MaxCount: 2000})
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, packed := range []string{"a4bc2d5e", "a9a3", "\\45", "ы9ы9ы"} {
+		unpacked, _ := unpackString(packed)
+		if repacked, _ := packString(unpacked); utf8.RuneCountInString(repacked) > utf8.RuneCountInString(packed) {
+			t.Errorf("expected packing of '%s' to be at most as long as '%s'; got '%s'", unpacked, packed, repacked)
+		}
+	}
+}
+
+// unpackCase - случай для table-driven тестов грамматик распаковки
+type unpackCase struct {
+	name      string
+	in        string
+	out       string
+	haveError bool
+}
+
+func runUnpackCases(t *testing.T, opts UnpackOptions, testTable []unpackCase) {
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			result, err := unpackWithOptions(testingCase.in, opts)
+			if testingCase.haveError {
+				if err == nil {
+					t.Errorf("expected error, but err is nil (result '%s')", result)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("expected err == nil; got '%s'", err.Error())
+			}
+			if result != testingCase.out {
+				t.Errorf("expected result '%s'; got '%s'", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestUnpackStrictDialect(t *testing.T) {
+	runUnpackCases(t, UnpackOptions{}, []unpackCase{
+		{name: "simple unpack", in: "a4bc2d5e", out: "aaaabccddddde"},
+		{name: "zero count", in: "ab0c", haveError: true},
+		{name: "multi-digit count", in: "a12", haveError: true},
+		{name: "braces are literal", in: "(a){2}", out: "(a){{}"},
+		{name: "escaped digit", in: "\\12", out: "11"},
+	})
+}
+
+func TestUnpackMultiDigitDialect(t *testing.T) {
+	runUnpackCases(t, UnpackOptions{MultiDigit: true}, []unpackCase{
+		{name: "two digits", in: "a12b", out: strings.Repeat("a", 12) + "b"},
+		{name: "single digits", in: "a4bc2d5e", out: "aaaabccddddde"},
+		{name: "leading zero", in: "a03", out: "aaa"},
+		{name: "escaped digit with count", in: "\\110", out: strings.Repeat("1", 10)},
+		{name: "escaped digit separates counts", in: "a2\\3b", out: "aa3b"},
+		{name: "leading digits", in: "12a", haveError: true},
+		{name: "zero count", in: "a00", haveError: true},
+		{name: "overflow", in: "a99999999999999999999", haveError: true},
+	})
+}
+
+func TestUnpackBracesDialect(t *testing.T) {
+	runUnpackCases(t, UnpackOptions{Braces: true}, []unpackCase{
+		{name: "explicit count", in: "a{12}b", out: strings.Repeat("a", 12) + "b"},
+		{name: "explicit count with single digits", in: "a{3}b2", out: "aaabb"},
+		{name: "group", in: "(ab){3}", out: "ababab"},
+		{name: "group with digit count", in: "(ab)2c", out: "ababc"},
+		{name: "nested groups", in: "((ab)2c){2}", out: "ababcababc"},
+		{name: "group without count", in: "x(yz)", out: "xyz"},
+		{name: "empty group", in: "a()3b", out: "ab"},
+		{name: "escaped braces", in: "\\{\\}\\(\\)", out: "{}()"},
+		{name: "digit after explicit count", in: "a{3}1", haveError: true},
+		{name: "multi-digit without option", in: "a12", haveError: true},
+		{name: "empty count", in: "a{}", haveError: true},
+		{name: "not a number", in: "a{x}", haveError: true},
+		{name: "unclosed count", in: "a{3", haveError: true},
+		{name: "stray closing brace", in: "a}", haveError: true},
+		{name: "count without atom", in: "{3}", haveError: true},
+		{name: "unclosed group", in: "(ab", haveError: true},
+		{name: "stray closing parenthesis", in: "ab)", haveError: true},
+		{name: "zero count", in: "(ab){0}", haveError: true},
+	})
+}
+
+func TestUnpackZeroDeletesDialect(t *testing.T) {
+	runUnpackCases(t, UnpackOptions{ZeroDeletes: true}, []unpackCase{
+		{name: "delete rune", in: "ab0c", out: "ac"},
+		{name: "delete escaped rune", in: "a\\40", out: "a"},
+		{name: "only deleted runes", in: "a0b0", out: ""},
+	})
+}
+
+func TestUnpackFullDialect(t *testing.T) {
+	runUnpackCases(t, UnpackOptions{MultiDigit: true, Braces: true, ZeroDeletes: true}, []unpackCase{
+		{name: "multi-digit group count", in: "(ab)10", out: strings.Repeat("ab", 10)},
+		{name: "delete group", in: "(ab){0}c", out: "c"},
+		{name: "delete with multi-digit zero", in: "a00b", out: "b"},
+		{name: "nested mixed counts", in: "(a{2}(b)3)2", out: "aabbbaabbb"},
+		{name: "explicit count ends number", in: "a{2}", out: "aa"},
+	})
+}
+
+func TestUnpackReader(t *testing.T) {
+	opts := UnpackOptions{MultiDigit: true, Braces: true, ZeroDeletes: true}
+	for _, in := range []string{"a4bc2d5e", "ы12日{3}", "((ab)2c){2}x0y", "(😀\\(){3}", ""} {
+		expected, err := unpackWithOptions(in, opts)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		// входные данные по одному байту, результат - по одному байту
+		reader := NewUnpackReader(iotest.OneByteReader(strings.NewReader(in)), opts)
+		result, err := io.ReadAll(iotest.OneByteReader(reader))
+		if err != nil {
+			t.Errorf("'%s': expected err == nil; got '%s'", in, err.Error())
+		}
+		if string(result) != expected {
+			t.Errorf("'%s': expected result '%s'; got '%s'", in, expected, result)
+		}
+	}
+}
+
+func TestUnpackReaderIsLazy(t *testing.T) {
+	// 100 МБ без ограничений: результат не накапливается в памяти целиком
+	reader := NewUnpackReader(strings.NewReader("a{100000000}b"), UnpackOptions{Braces: true, MaxOutput: -1, MaxRatio: -1})
+	n, err := io.Copy(io.Discard, reader)
+	if err != nil || n != 100000001 {
+		t.Errorf("expected 100000001 bytes without error; got %d, %v", n, err)
+	}
+}
+
+func TestUnpackReaderLimits(t *testing.T) {
+	testTable := []struct {
+		name   string
+		in     string
+		opts   UnpackOptions
+		limit  string
+		offset int64
+	}{
+		{
+			name: "nested groups bomb", in: strings.Repeat("(", 9) + "a9" + strings.Repeat(")9", 8) + ")9",
+			opts: UnpackOptions{Braces: true}, limit: "expansion ratio", offset: 23,
+		},
+		{
+			name: "output size", in: "abc{10}d",
+			opts: UnpackOptions{Braces: true, MaxOutput: 10}, limit: "output size", offset: 7,
+		},
+		{
+			name: "expansion ratio", in: "a{1000}",
+			opts: UnpackOptions{Braces: true, MaxRatio: 100}, limit: "expansion ratio", offset: 7,
+		},
+		{
+			name: "repeated runs", in: strings.Repeat("a9", 1000),
+			opts: UnpackOptions{MaxOutput: 100}, limit: "output size", offset: 24,
+		},
+		{
+			name: "group without limits", in: "(a{9223372036854775807}){9223372036854775807}",
+			opts: UnpackOptions{Braces: true, MaxOutput: -1, MaxRatio: -1}, limit: "group size", offset: 23,
+		},
+		{
+			name: "deeply nested groups", in: strings.Repeat("(", 20<<20),
+			opts: UnpackOptions{Braces: true}, limit: "group depth", offset: DefaultMaxDepth + 1,
+		},
+		{
+			name: "nested groups over MaxDepth", in: "((((a))))",
+			opts: UnpackOptions{Braces: true, MaxDepth: 3}, limit: "group depth", offset: 4,
+		},
+		{
+			name: "overflow without limits", in: "b{2}a{9223372036854775807}",
+			opts: UnpackOptions{Braces: true, MaxOutput: -1, MaxRatio: -1}, limit: "output size", offset: 26,
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			_, err := io.Copy(io.Discard, NewUnpackReader(strings.NewReader(testingCase.in), testingCase.opts))
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("expected LimitError; got %v", err)
+			}
+			if limitErr.Limit != testingCase.limit || limitErr.Offset != testingCase.offset {
+				t.Errorf("expected %s limit at byte %d; got %s at byte %d", testingCase.limit, testingCase.offset,
+					limitErr.Limit, limitErr.Offset)
+			}
+		})
+	}
+
+	// вложенность, равная MaxDepth, допустима
+	if result, err := unpackWithOptions("(((a)))2", UnpackOptions{Braces: true, MaxDepth: 3}); err != nil || result != "aa" {
+		t.Errorf("expected 'aa'; got '%s', %v", result, err)
+	}
+}
+
+func TestUnpackReaderParseErrors(t *testing.T) {
+	testTable := []struct {
+		name   string
+		in     string
+		opts   UnpackOptions
+		out    string
+		offset int64
+	}{
+		{name: "leading digit", in: "45", offset: 0},
+		{name: "digit after count", in: "ab3c45", out: "abbbcccc", offset: 5},
+		{name: "multibyte runes", in: "ыы45", out: "ыыыыы", offset: 5},
+		{name: "not a number in braces", in: "a{3x}", opts: UnpackOptions{Braces: true}, offset: 3},
+		{name: "unclosed group", in: "x(ab", opts: UnpackOptions{Braces: true}, out: "x", offset: 1},
+		{name: "zero count", in: "日a0", out: "日", offset: 4},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			result, err := io.ReadAll(NewUnpackReader(strings.NewReader(testingCase.in), testingCase.opts))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ParseError; got %v", err)
+			}
+			if parseErr.Offset != testingCase.offset {
+				t.Errorf("expected offset %d; got %d", testingCase.offset, parseErr.Offset)
+			}
+			// все, что было до ошибки, уже выдано
+			if string(result) != testingCase.out {
+				t.Errorf("expected partial result '%s'; got '%s'", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestParseErrorReason(t *testing.T) {
+	braces := UnpackOptions{Braces: true}
+	testTable := []struct {
+		name   string
+		in     string
+		opts   UnpackOptions
+		reason ParseReason
+		pos    int64
+		symbol rune
+	}{
+		{name: "leading digit", in: "45", opts: legacyUnpackOptions, reason: ReasonLeadingDigit, pos: 0, symbol: '4'},
+		{name: "digit after count", in: "ыы45", reason: ReasonLeadingDigit, pos: 3, symbol: '5'},
+		{name: "dangling escape", in: "qwe\\", opts: legacyUnpackOptions, reason: ReasonDanglingEscape, pos: 3, symbol: '\\'},
+		{name: "dangling escape after escape", in: "日\\\\\\", reason: ReasonDanglingEscape, pos: 3, symbol: '\\'},
+		{name: "invalid escape target", in: "q\\we", reason: ReasonInvalidEscape, pos: 2, symbol: 'w'},
+		{name: "escaped brace without braces", in: "a\\{", reason: ReasonInvalidEscape, pos: 2, symbol: '{'},
+		{name: "overflow", in: "ы99999999999999999999", opts: UnpackOptions{MultiDigit: true}, reason: ReasonOverflow, pos: 1, symbol: '9'},
+		{name: "zero count", in: "ab0", reason: ReasonZeroCount, pos: 2, symbol: '0'},
+		{name: "stray closing brace", in: "a}", opts: braces, reason: ReasonUnexpectedRune, pos: 1, symbol: '}'},
+		{name: "invalid count", in: "a{3x}", opts: braces, reason: ReasonInvalidCount, pos: 3, symbol: 'x'},
+		{name: "unclosed count", in: "a{3", opts: braces, reason: ReasonUnclosedCount, pos: 1, symbol: '{'},
+		{name: "unclosed group", in: "x(ab", opts: braces, reason: ReasonUnclosedGroup, pos: 1, symbol: '('},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			_, err := unpackWithOptions(testingCase.in, testingCase.opts)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ParseError; got %v", err)
+			}
+			if parseErr.Reason != testingCase.reason || parseErr.Pos != testingCase.pos || parseErr.Rune != testingCase.symbol {
+				t.Errorf("expected %s %q at %d; got %s %q at %d", testingCase.reason, testingCase.symbol, testingCase.pos,
+					parseErr.Reason, parseErr.Rune, parseErr.Pos)
+			}
+			if !errors.Is(err, errIncorrectString) {
+				t.Errorf("expected error to wrap errIncorrectString")
+			}
+		})
+	}
+}
+
+func TestParseErrorDiagnostic(t *testing.T) {
+	_, err := unpackString("ы\tb45")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ParseError; got %v", err)
+	}
+	expected := "ы\tb45\n \t  ^ digit without a preceding rune"
+	if diagnostic := parseErr.Diagnostic("ы\tb45"); diagnostic != expected {
+		t.Errorf("expected diagnostic %q; got %q", expected, diagnostic)
+	}
+}
+
+func TestUnpackReaderSourceError(t *testing.T) {
+	errBoom := errors.New("boom")
+	reader := NewUnpackReader(io.MultiReader(strings.NewReader("a3"), iotest.ErrReader(errBoom)), UnpackOptions{})
+	result, err := io.ReadAll(reader)
+	if !errors.Is(err, errBoom) || string(result) != "aaa" {
+		t.Errorf("expected 'aaa' and source error; got '%s', %v", result, err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"wbschool_exam_L2/develop/dev03/sortutil"
+)
+
+// inputs - открытые входные файлы
+type inputs []sortutil.Input
+
+// openInputs - входные файлы по порядку; без файлов читается стандартный ввод
+func openInputs(files []string, stdin io.Reader) (inputs, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	opened := make(inputs, 0, len(files))
+	for _, name := range files {
+		if name == "-" {
+			opened = append(opened, sortutil.Input{Name: name, R: stdin})
+			continue
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			opened.close()
+			return nil, readError(name, err)
+		}
+		opened = append(opened, sortutil.Input{Name: name, R: file})
+	}
+	return opened, nil
+}
+
+func (in inputs) close() {
+	for _, input := range in {
+		if file, ok := input.R.(*os.File); ok && input.Name != "-" {
+			file.Close()
+		}
+	}
+}
+
+func readError(name string, err error) error {
+	return fmt.Errorf("can not read file '%s': %s", name, err.Error())
+}
+
+// outputFile - вывод -o во временный файл в каталоге выходного файла; выходной файл заменяется
+// только после успешной сортировки, поэтому он может быть и одним из входных
+type outputFile struct {
+	*os.File
+	name string
+}
+
+func createOutput(name string) (*outputFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".sort-")
+	if err != nil {
+		return nil, fmt.Errorf("can not create file '%s': %s", name, err.Error())
+	}
+
+	// права существующего файла сохраняются, новый файл создается с 0644
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("can not create file '%s': %s", name, err.Error())
+	}
+	return &outputFile{File: file, name: name}, nil
+}
+
+// commit - замена выходного файла временным
+func (o *outputFile) commit() error {
+	if err := o.Close(); err != nil {
+		o.abort()
+		return fmt.Errorf("can not write file '%s': %s", o.name, err.Error())
+	}
+	if err := os.Rename(o.Name(), o.name); err != nil {
+		o.abort()
+		return fmt.Errorf("can not write file '%s': %s", o.name, err.Error())
+	}
+	return nil
+}
+
+// abort - удаление временного файла; выходной файл остается прежним
+func (o *outputFile) abort() {
+	o.Close()
+	os.Remove(o.Name())
+}
+
+// sortToOutput - сортировка с выводом в файл s.output или в stdout
+func sortToOutput(s *SortConfig, stdin io.Reader, stdout io.Writer) error {
+	if s.output == "" {
+		return sortFiles(s, stdin, stdout)
+	}
+
+	out, err := createOutput(s.output)
+	if err != nil {
+		return err
+	}
+	if err := sortFiles(s, stdin, out); err != nil {
+		out.abort()
+		return err
+	}
+	return out.commit()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wbschool_exam_L2/develop/dev03/sortutil"
+)
+
+// writeFiles - файлы с заданным содержимым во временном каталоге, пути в том же порядке
+func writeFiles(t *testing.T, contents ...string) []string {
+	dir := t.TempDir()
+	names := make([]string, len(contents))
+	for i, content := range contents {
+		names[i] = filepath.Join(dir, string(rune('a'+i))+".txt")
+		if err := os.WriteFile(names[i], []byte(content), 0o644); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	return names
+}
+
+func TestSortFiles(t *testing.T) {
+	files := writeFiles(t, "delta\nalpha", "charlie 2\nbravo\n", "alpha 1\ncharlie 1\necho\n", "bravo 2\ncharlie 3\n")
+	key1, err := sortutil.ParseKeySpec("1,1")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testTable := []struct {
+		name  string
+		sc    SortConfig
+		stdin string
+		out   string
+	}{
+		{
+			name: "files without trailing newline are not joined",
+			sc:   SortConfig{files: files[:2]},
+			out:  "alpha\nbravo\ncharlie 2\ndelta\n",
+		},
+		{
+			name:  "stdin without files",
+			stdin: "b\na\n",
+			out:   "a\nb\n",
+		},
+		{
+			name:  "stdin as dash between files",
+			sc:    SortConfig{Config: sortutil.Config{Keys: []sortutil.KeySpec{key1}, Unique: true}, files: []string{files[0], "-"}},
+			stdin: "alpha\nzulu\n",
+			out:   "alpha\ndelta\nzulu\n",
+		},
+		{
+			name: "merge sorted files",
+			sc:   SortConfig{files: files[2:], mergeOnly: true},
+			out:  "alpha 1\nbravo 2\ncharlie 1\ncharlie 3\necho\n",
+		},
+		{
+			name: "merge keeps file order of equal keys with -s",
+			sc:   SortConfig{Config: sortutil.Config{Keys: []sortutil.KeySpec{key1}, Stable: true}, files: []string{files[3], files[2]}, mergeOnly: true},
+			out:  "alpha 1\nbravo 2\ncharlie 3\ncharlie 1\necho\n",
+		},
+		{
+			name: "merge compares equal keys as whole lines",
+			sc:   SortConfig{Config: sortutil.Config{Keys: []sortutil.KeySpec{key1}}, files: []string{files[3], files[2]}, mergeOnly: true},
+			out:  "alpha 1\nbravo 2\ncharlie 1\ncharlie 3\necho\n",
+		},
+		{
+			name: "merge unique by key keeps first file",
+			sc:   SortConfig{Config: sortutil.Config{Keys: []sortutil.KeySpec{key1}, Unique: true}, files: []string{files[3], files[2]}, mergeOnly: true},
+			out:  "alpha 1\nbravo 2\ncharlie 3\necho\n",
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := sortFiles(&testingCase.sc, strings.NewReader(testingCase.stdin), &out); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if out.String() != testingCase.out {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", testingCase.out, out.String())
+			}
+		})
+	}
+}
+
+func TestSortToOutput(t *testing.T) {
+	files := writeFiles(t, "c\na\n", "b\n")
+	dir := filepath.Dir(files[0])
+
+	// выходной файл - один из входных
+	sc := SortConfig{files: files, output: files[0]}
+	if err := sortToOutput(&sc, nil, nil); err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+	if data, _ := os.ReadFile(files[0]); string(data) != "a\nb\nc\n" {
+		t.Errorf("expected sorted output in %s; got %q", files[0], data)
+	}
+
+	// при ошибке выходной файл не меняется, временный удаляется
+	sc = SortConfig{files: []string{files[1], filepath.Join(dir, "missing.txt")}, output: files[0]}
+	if err := sortToOutput(&sc, nil, nil); err == nil {
+		t.Errorf("expected error, but err is nil")
+	}
+	if data, _ := os.ReadFile(files[0]); string(data) != "a\nb\nc\n" {
+		t.Errorf("expected %s to be kept; got %q", files[0], data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("expected temporary output to be removed; got %d entries", len(entries))
+	}
+
+	// новый файл
+	sc = SortConfig{files: files[1:], output: filepath.Join(dir, "new.txt"), mergeOnly: true}
+	if err := sortToOutput(&sc, nil, nil); err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+	if data, _ := os.ReadFile(sc.output); string(data) != "b\n" {
+		t.Errorf("expected %q; got %q", "b\n", data)
+	}
+}
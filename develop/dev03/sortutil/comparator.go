@@ -0,0 +1,77 @@
+package sortutil
+
+// Comparator - сравнение двух строк: < 0, если a идет раньше b, 0 - строки равны по ключам.
+// Сравнители складываются в цепочку через Then: следующий разрешает равенство предыдущего
+type Comparator func(a, b string) int
+
+// Then - сравнение c, а при равенстве - next
+func (c Comparator) Then(next Comparator) Comparator {
+	return func(a, b string) int {
+		if cmp := c(a, b); cmp != 0 {
+			return cmp
+		}
+		return next(a, b)
+	}
+}
+
+// Reversed - сравнение c в обратном порядке
+func (c Comparator) Reversed() Comparator {
+	return func(a, b string) int {
+		return c(b, a)
+	}
+}
+
+// Less - функция для sort.Slice и аналогов: sort.SliceStable(lines, c.Less(lines))
+func (c Comparator) Less(lines []string) func(i, j int) bool {
+	return func(i, j int) bool {
+		return c(lines[i], lines[j]) < 0
+	}
+}
+
+// KeyComparator - сравнение строк по одному ключу с глобальными флагами s: разбиение на поля
+// по Separator и CSV, модификаторы ключа или, если их нет, глобальные флаги. key создается ParseKeySpec;
+// ключ и флаги проверяются Validate, ошибка - как у Sort
+func (s Config) KeyComparator(key KeySpec) (Comparator, error) {
+	s.Keys = []KeySpec{key}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s.keyComparator(key), nil
+}
+
+// Comparator - сравнение строк по ключам s по очереди, как при сортировке; без ключей - по всей строке.
+// При равенстве ключей без Stable, Unique и Count строки сравниваются побайтно, как в GNU sort.
+// Config проверяется Validate. С Collator сравнитель нельзя вызывать из нескольких горутин одновременно
+func (s Config) Comparator() (Comparator, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	cmp := s.lineComparator()
+	if len(s.Keys) != 0 {
+		cmp = s.keyComparator(s.Keys[0])
+		for _, key := range s.Keys[1:] {
+			cmp = cmp.Then(s.keyComparator(key))
+		}
+	}
+	if !s.lastResort() {
+		return cmp, nil
+	}
+	return cmp.Then(func(a, b string) int {
+		return lastResortCompare(a, b, &s)
+	}), nil
+}
+
+// keyComparator - KeyComparator без проверки
+func (s Config) keyComparator(key KeySpec) Comparator {
+	s.Keys = []KeySpec{key}
+	return s.lineComparator()
+}
+
+// lineComparator - compareRecords для отдельных строк
+func (s Config) lineComparator() Comparator {
+	return func(a, b string) int {
+		recA, recB := newRecord(a, 0, &s), newRecord(b, 0, &s)
+		return compareRecords(&recA, &recB, &s)
+	}
+}
@@ -0,0 +1,75 @@
+package sortutil
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestComparator(t *testing.T) {
+	lines := []string{"b 2", "a 10", "c 2", "a 1"}
+	byNumber, _ := Config{}.KeyComparator(mustParseKeys("2,2n")[0])
+	byWord, _ := Config{}.KeyComparator(mustParseKeys("1,1")[0])
+	byNumericSort, _ := Config{NumericSort: true}.KeyComparator(mustParseKeys("2,2")[0])
+	wholeLine, _ := Config{}.Comparator()
+	byKeys, _ := Config{Keys: mustParseKeys("2,2n", "1,1r")}.Comparator()
+
+	testTable := []struct {
+		name string
+		cmp  Comparator
+		out  string
+	}{
+		{name: "whole line", cmp: wholeLine, out: "a 1|a 10|b 2|c 2"},
+		{name: "keys in order", cmp: byKeys, out: "a 1|c 2|b 2|a 10"},
+		{name: "then", cmp: byNumber.Then(byWord), out: "a 1|b 2|c 2|a 10"},
+		{name: "reversed keeps equal keys stable", cmp: byNumericSort.Reversed(), out: "a 10|b 2|c 2|a 1"},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			result := append([]string(nil), lines...)
+			sort.SliceStable(result, testingCase.cmp.Less(result))
+			if strings.Join(result, "|") != testingCase.out {
+				t.Errorf("expected '%s'; got '%s'", testingCase.out, strings.Join(result, "|"))
+			}
+		})
+	}
+}
+
+func TestComparatorInvalidConfig(t *testing.T) {
+	testTable := []struct {
+		name string
+		sc   Config
+		// key - ключ для KeyComparator; nil - проверяется Comparator
+		key *KeySpec
+		out string
+	}{
+		{name: "zero key", key: &KeySpec{}, out: "key 1: field and character numbers start at 1, use ParseKeySpec"},
+		{
+			name: "zero key in config", sc: Config{Keys: append(mustParseKeys("1,1"), KeySpec{})},
+			out: "key 2: field and character numbers start at 1, use ParseKeySpec",
+		},
+		{name: "incompatible orders", sc: Config{NumericSort: true, MonthSort: true}, out: "options '-Mn' are incompatible"},
+		{
+			name: "incompatible orders with key", sc: Config{DictionaryOrder: true, NumericSort: true}, key: &mustParseKeys("1,1")[0],
+			out: "options '-dn' are incompatible",
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var err error
+			if testingCase.key != nil {
+				_, err = testingCase.sc.KeyComparator(*testingCase.key)
+			} else {
+				_, err = testingCase.sc.Comparator()
+			}
+			if err == nil {
+				t.Fatalf("expected error '%s'; got nil", testingCase.out)
+			}
+			if err.Error() != testingCase.out {
+				t.Errorf("expected error '%s'; got '%s'", testingCase.out, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package sortutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// compareValues - сравнение значений ключа
+func (s *Config) compareValues(a, b string, opts keyOptions) int {
+	var cmp int
+	switch opts.order {
+	case orderNumeric:
+		cmp = compareNumbers(a, b)
+	case orderHuman:
+		cmp = compareHuman(a, b)
+	case orderGeneral:
+		cmp = compareGeneral(a, b)
+	case orderVersion:
+		cmp = compareVersions(a, b)
+	default:
+		// текст с -d, -f и -collate, месяцы и -R уже приведены к побайтному сравнению, см. keyValue
+		cmp = strings.Compare(a, b)
+	}
+
+	if opts.reverse {
+		return -cmp
+	}
+	return cmp
+}
+
+// precomputed - ключ сравнивается не как есть, а по значению keyValue
+func (s *Config) precomputed(opts keyOptions) bool {
+	return opts.order == orderMonth || opts.order == orderRandom || s.transformsText(opts)
+}
+
+// keyValue - значение ключа в том виде, в котором оно сравнивается; вычисляется один раз при создании записи
+func (s *Config) keyValue(value string, opts keyOptions) string {
+	switch opts.order {
+	case orderMonth:
+		return s.monthKey(value)
+	case orderRandom:
+		return s.randomKey(value, opts)
+	default:
+		return s.textKey(value, opts)
+	}
+}
+
+// transformsText - текстовый ключ сравнивается не как есть, а по значению textKey
+func (s *Config) transformsText(opts keyOptions) bool {
+	return opts.order == orderText && (opts.dictionary || opts.fold || s.Collator != nil)
+}
+
+// textKey - значение текстового ключа, которое сравнивается побайтно: с -d остаются только буквы,
+// цифры и пробелы, с -f строчные буквы приводятся к прописным, с -collate значение заменяется
+// ключом сортировки Unicode Collation Algorithm
+func (s *Config) textKey(value string, opts keyOptions) string {
+	if !s.transformsText(opts) {
+		return value
+	}
+	if opts.dictionary {
+		value = strings.Map(dictionaryRune, value)
+	}
+	if opts.fold {
+		value = strings.ToUpper(value)
+	}
+	if s.Collator != nil {
+		var buf collate.Buffer
+		value = string(s.Collator.KeyFromString(&buf, value))
+	}
+	return value
+}
+
+// dictionaryRune - руна для словарного порядка: буквы, цифры и пробелы остаются, остальные отбрасываются
+func dictionaryRune(r rune) rune {
+	if r == ' ' || r == '\t' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return r
+	}
+	return -1
+}
+
+// NewCollator - правила сравнения строк для языка: "ru", "en", "und" - общие правила Unicode.
+// Collator не потокобезопасен: ключи сортировки вычисляются при чтении строк в одной горутине
+func NewCollator(value string) (*collate.Collator, error) {
+	tag, err := language.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collation language '%s': %s", value, err.Error())
+	}
+	return collate.New(tag), nil
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareNumbers - сравнение десятичных чисел (-n): пробелы в начале, знак '-', целая и дробная часть.
+// Числа сравниваются как строки цифр, без потери точности; нечисловое значение равно 0
+func compareNumbers(a, b string) int {
+	negA, intA, fracA := parseDecimal(a)
+	negB, intB, fracB := parseDecimal(b)
+	if negA != negB {
+		if negA {
+			return -1
+		}
+		return 1
+	}
+
+	cmp := compareInts(len(intA), len(intB))
+	if cmp == 0 {
+		cmp = strings.Compare(intA, intB)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(fracA, fracB)
+	}
+	if negA {
+		return -cmp
+	}
+	return cmp
+}
+
+// parseDecimal - знак, целая часть без ведущих нулей и дробная часть без завершающих нулей; у нуля нет знака
+func parseDecimal(str string) (bool, string, string) {
+	str = strings.TrimLeft(str, " \t")
+	negative := strings.HasPrefix(str, "-")
+	if negative {
+		str = str[1:]
+	}
+
+	end := 0
+	for end < len(str) && isASCIIDigit(str[end]) {
+		end++
+	}
+	integer := strings.TrimLeft(str[:end], "0")
+	var fraction string
+	if end < len(str) && str[end] == '.' {
+		start := end + 1
+		for end = start; end < len(str) && isASCIIDigit(str[end]); end++ {
+		}
+		fraction = strings.TrimRight(str[start:end], "0")
+	}
+
+	if integer == "" && fraction == "" {
+		return false, "", ""
+	}
+	return negative, integer, fraction
+}
+
+// generalClass - порядок значений -g: не числа, NaN, -Inf, числа, +Inf
+const (
+	generalNotNumber = iota
+	generalNaN
+	generalNumber
+)
+
+// compareGeneral - сравнение чисел с плавающей точкой (-g): экспонента, inf и nan в любом регистре.
+// Значения без числа в начале идут первыми, затем NaN, затем числа по возрастанию
+func compareGeneral(a, b string) int {
+	classA, numA := parseGeneral(a)
+	classB, numB := parseGeneral(b)
+	if classA != classB || classA != generalNumber {
+		return compareInts(classA, classB)
+	}
+	switch {
+	case numA < numB:
+		return -1
+	case numA > numB:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseGeneral - самое длинное число с плавающей точкой в начале строки
+func parseGeneral(str string) (int, float64) {
+	str = strings.TrimLeft(str, " \t")
+	start := 0
+	if str != "" && (str[0] == '+' || str[0] == '-') {
+		start = 1
+	}
+
+	end := scanFloat(str, start)
+	if end == start {
+		lower := strings.ToLower(str[start:])
+		switch {
+		case strings.HasPrefix(lower, "nan"):
+			return generalNaN, 0
+		case strings.HasPrefix(lower, "inf") && strings.HasPrefix(str, "-"):
+			return generalNumber, math.Inf(-1)
+		case strings.HasPrefix(lower, "inf"):
+			return generalNumber, math.Inf(1)
+		default:
+			return generalNotNumber, 0
+		}
+	}
+
+	// за пределами float64 ParseFloat возвращает ±Inf или 0 вместе с ErrRange
+	number, err := strconv.ParseFloat(str[:end], 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return generalNotNumber, 0
+	}
+	return generalNumber, number
+}
+
+// scanFloat - конец записи числа digits[.digits][e[+-]digits], начиная с позиции start
+func scanFloat(str string, start int) int {
+	end := start
+	digits := 0
+	for ; end < len(str) && isASCIIDigit(str[end]); end++ {
+		digits++
+	}
+	if end < len(str) && str[end] == '.' {
+		for end++; end < len(str) && isASCIIDigit(str[end]); end++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return start
+	}
+
+	// экспонента учитывается, только если после нее есть цифры
+	if end < len(str) && (str[end] == 'e' || str[end] == 'E') {
+		exp := end + 1
+		if exp < len(str) && (str[exp] == '+' || str[exp] == '-') {
+			exp++
+		}
+		if exp < len(str) && isASCIIDigit(str[exp]) {
+			for end = exp; end < len(str) && isASCIIDigit(str[end]); end++ {
+			}
+		}
+	}
+	return end
+}
+
+// humanSuffixes - множители -h по порядку: K = 1, M = 2, ...
+const humanSuffixes = "KMGTPEZY"
+
+// compareHuman - сравнение чисел с суффиксами (-h) по значению: "1.5K" < "2M" < "0.5G".
+// IEC: "K", "Ki", "KiB" - степени 1024, как в выводе du -h и ls -h; SI: "k", "kB", "MB" - степени 1000
+func compareHuman(a, b string) int {
+	numA, numB := parseHuman(a), parseHuman(b)
+	switch {
+	case numA < numB:
+		return -1
+	case numA > numB:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseHuman - значение числа вида "-1.5K"; нечисловое значение равно 0
+func parseHuman(str string) float64 {
+	str = strings.TrimLeft(str, " \t")
+	end := 0
+	if strings.HasPrefix(str, "-") {
+		end++
+	}
+	end = scanDecimal(str, end)
+	number, err := strconv.ParseFloat(str[:end], 64)
+	if err != nil || end == len(str) {
+		return number
+	}
+
+	letter, unit := str[end], str[end+1:]
+	power := strings.IndexByte(humanSuffixes, letter) + 1
+	if letter == 'k' {
+		power = 1
+	}
+	if power == 0 {
+		return number
+	}
+
+	base := 1024.0
+	if letter == 'k' || strings.HasPrefix(unit, "B") {
+		base = 1000
+	}
+	return number * math.Pow(base, float64(power))
+}
+
+// scanDecimal - конец записи числа digits[.digits], начиная с позиции start
+func scanDecimal(str string, start int) int {
+	end := start
+	for end < len(str) && isASCIIDigit(str[end]) {
+		end++
+	}
+	if end < len(str) && str[end] == '.' {
+		for end++; end < len(str) && isASCIIDigit(str[end]); end++ {
+		}
+	}
+	return end
+}
+
+// compareVersions - сравнение номеров версий (алгоритм verrevcmp из dpkg): числа сравниваются
+// по значению, остальные символы - посимвольно, '~' меньше всего, даже конца строки
+func compareVersions(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isASCIIDigit(a[i]) || j < len(b) && !isASCIIDigit(b[j]) {
+			orderA, orderB := versionOrder(a, i), versionOrder(b, j)
+			if orderA != orderB {
+				return compareInts(orderA, orderB)
+			}
+			i++
+			j++
+		}
+
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for i < len(a) && isASCIIDigit(a[i]) && j < len(b) && isASCIIDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = compareInts(int(a[i]), int(b[j]))
+			}
+			i++
+			j++
+		}
+		// более длинное число больше
+		if i < len(a) && isASCIIDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isASCIIDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
+
+// versionOrder - вес символа в нечисловой части версии: конец строки и цифры - 0, буквы - раньше прочих
+func versionOrder(str string, i int) int {
+	if i >= len(str) {
+		return 0
+	}
+	switch c := str[i]; {
+	case c == '~':
+		return -1
+	case isASCIIDigit(c):
+		return 0
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
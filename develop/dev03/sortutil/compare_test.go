@@ -0,0 +1,223 @@
+package sortutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareNumbers(t *testing.T) {
+	ordered := []string{"-100", "-9.5", "-9.25", "-1", "-0.001", "0", "0.001", "1", "1.5", "9", "10", "010.50", "100", "99999999999999999999999"}
+	for i := range ordered {
+		for j := range ordered {
+			if cmp := compareNumbers(ordered[i], ordered[j]); cmp != compareInts(i, j) {
+				t.Errorf("compareNumbers(%q, %q) = %d; expected %d", ordered[i], ordered[j], cmp, compareInts(i, j))
+			}
+		}
+	}
+
+	equal := [][2]string{{"-0", "0"}, {"foo", "0"}, {"", "-"}, {"007", "7.000"}, {"  12", "12abc"}, {".5", "0.50"}}
+	for _, pair := range equal {
+		if cmp := compareNumbers(pair[0], pair[1]); cmp != 0 {
+			t.Errorf("compareNumbers(%q, %q) = %d; expected 0", pair[0], pair[1], cmp)
+		}
+	}
+}
+
+func TestCompareGeneral(t *testing.T) {
+	ordered := []string{"foo", "nan", "-inf", "-1e10", "-2.5", "0", "1e-3", "0.5", "2", "1.5e3", "1E+10", "1e300", "inf"}
+	for i := range ordered {
+		for j := range ordered {
+			if cmp := compareGeneral(ordered[i], ordered[j]); cmp != compareInts(i, j) {
+				t.Errorf("compareGeneral(%q, %q) = %d; expected %d", ordered[i], ordered[j], cmp, compareInts(i, j))
+			}
+		}
+	}
+
+	equal := [][2]string{{"", "bar"}, {"NaN", "-nan"}, {"1e", "1"}, {"1000", "1e3"}, {" -Infinity", "-inf"}}
+	for _, pair := range equal {
+		if cmp := compareGeneral(pair[0], pair[1]); cmp != 0 {
+			t.Errorf("compareGeneral(%q, %q) = %d; expected 0", pair[0], pair[1], cmp)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	ordered := []string{"", "1.0~rc1", "1.0", "1.0a", "1.0.1", "1.2.9", "1.2.10", "1.10", "2", "10.0"}
+	for i := range ordered {
+		for j := range ordered {
+			if cmp := compareVersions(ordered[i], ordered[j]); cmp != compareInts(i, j) {
+				t.Errorf("compareVersions(%q, %q) = %d; expected %d", ordered[i], ordered[j], cmp, compareInts(i, j))
+			}
+		}
+	}
+	if compareVersions("1.01", "1.1") != 0 {
+		t.Errorf("expected leading zeros to be ignored")
+	}
+}
+
+func TestCompareHuman(t *testing.T) {
+	ordered := []string{"-2G", "-1K", "-900", "0", "foo", "1", "900", "1K", "1.5K", "2k", "1M", "0.5G", "1T", "1P"}
+	for i := range ordered {
+		for j := range ordered {
+			expected := compareInts(i, j)
+			// нечисловое значение равно нулю
+			if ordered[i] == "0" && ordered[j] == "foo" || ordered[i] == "foo" && ordered[j] == "0" {
+				expected = 0
+			}
+			if cmp := compareHuman(ordered[i], ordered[j]); cmp != expected {
+				t.Errorf("compareHuman(%q, %q) = %d; expected %d", ordered[i], ordered[j], cmp, expected)
+			}
+		}
+	}
+}
+
+func TestSortByGlobalOrdering(t *testing.T) {
+	testTable := []struct {
+		name string
+		sc   Config
+		in   []string
+		out  []string
+	}{
+		{
+			name: "human numeric -h",
+			sc:   Config{HumanNumericSort: true},
+			in:   []string{"1G", "512", "2k", "1.5K", "10M", "3MB"},
+			out:  []string{"512", "1.5K", "2k", "3MB", "10M", "1G"},
+		},
+		{
+			name: "general numeric -g",
+			sc:   Config{GeneralNumericSort: true},
+			in:   []string{"1e3", "nan", "-inf", "12", "abc", "2.5E2"},
+			out:  []string{"abc", "nan", "-inf", "12", "2.5E2", "1e3"},
+		},
+		{
+			name: "version -V reverse",
+			sc:   Config{VersionSort: true, Reverse: true},
+			in:   []string{"go1.9", "go1.17", "go1.17rc1", "go1.10"},
+			out:  []string{"go1.17rc1", "go1.17", "go1.10", "go1.9"},
+		},
+		{
+			name: "exact numeric -n",
+			sc:   Config{NumericSort: true},
+			in:   []string{"12345678901234567891", "12345678901234567890", "-0.5", "-0.25"},
+			out:  []string{"-0.5", "-0.25", "12345678901234567890", "12345678901234567891"},
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var out strings.Builder
+			in := strings.Join(testingCase.in, "\n")
+			if err := Sort(strings.NewReader(in), &out, testingCase.sc); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			expected := strings.Join(testingCase.out, "\n") + "\n"
+			if out.String() != expected {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", expected, out.String())
+			}
+		})
+	}
+}
+
+func TestOrderFlags(t *testing.T) {
+	s := Config{NumericSort: true, MonthSort: true, VersionSort: true}
+	if orders := s.orderFlags(); orders != "MnV" {
+		t.Errorf("expected 'MnV'; got '%s'", orders)
+	}
+	if orders := (&Config{HumanNumericSort: true}).orderFlags(); orders != "h" {
+		t.Errorf("expected 'h'; got '%s'", orders)
+	}
+	if orders := (&Config{DictionaryOrder: true, FoldCase: true, NumericSort: true}).orderFlags(); orders != "dn" {
+		t.Errorf("expected 'dn'; got '%s'", orders)
+	}
+}
+
+func TestSortByCollation(t *testing.T) {
+	russian, err := NewCollator("ru")
+	if err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+	input := strings.Join([]string{"Яблоко", "жук", "ёж", "яблоко", "Ежевика", "еж", "арбуз"}, "\n")
+
+	testTable := []struct {
+		name string
+		sc   Config
+		out  []string
+	}{
+		{
+			name: "bytes",
+			out:  []string{"Ежевика", "Яблоко", "арбуз", "еж", "жук", "яблоко", "ёж"},
+		},
+		{
+			name: "fold case -f",
+			sc:   Config{FoldCase: true},
+			out:  []string{"ёж", "арбуз", "еж", "Ежевика", "жук", "Яблоко", "яблоко"},
+		},
+		{
+			name: "russian collation",
+			sc:   Config{Collator: russian},
+			out:  []string{"арбуз", "еж", "ёж", "Ежевика", "жук", "яблоко", "Яблоко"},
+		},
+		{
+			name: "russian collation with fold keeps input order of equal lines",
+			sc:   Config{Collator: russian, FoldCase: true, Reverse: true, Stable: true},
+			out:  []string{"Яблоко", "яблоко", "жук", "Ежевика", "ёж", "еж", "арбуз"},
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Sort(strings.NewReader(input), &out, testingCase.sc); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			expected := strings.Join(testingCase.out, "\n") + "\n"
+			if out.String() != expected {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", expected, out.String())
+			}
+		})
+	}
+}
+
+func TestSortByDictionaryOrder(t *testing.T) {
+	input := strings.Join([]string{"2 «Вишня»", "\"Арбуз\" 1", "(Банан) 3", "Арбуз 0"}, "\n")
+
+	testTable := []struct {
+		name string
+		sc   Config
+		out  []string
+	}{
+		{
+			name: "dictionary order -d",
+			sc:   Config{DictionaryOrder: true},
+			out:  []string{"2 «Вишня»", "Арбуз 0", "\"Арбуз\" 1", "(Банан) 3"},
+		},
+		{
+			name: "dictionary order key modifier",
+			sc:   Config{Keys: mustParseKeys("1,1df", "2,2n")},
+			out:  []string{"2 «Вишня»", "Арбуз 0", "\"Арбуз\" 1", "(Банан) 3"},
+		},
+		{
+			name: "dictionary order ignores punctuation only",
+			sc:   Config{Keys: mustParseKeys("2d")},
+			out:  []string{"Арбуз 0", "\"Арбуз\" 1", "(Банан) 3", "2 «Вишня»"},
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Sort(strings.NewReader(input), &out, testingCase.sc); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			expected := strings.Join(testingCase.out, "\n") + "\n"
+			if out.String() != expected {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", expected, out.String())
+			}
+		})
+	}
+
+	if _, err := NewCollator("not a language"); err == nil {
+		t.Errorf("expected error for invalid language tag, but err is nil")
+	}
+}
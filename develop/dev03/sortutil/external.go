@@ -0,0 +1,433 @@
+package sortutil
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// defaultBufferSize - объем памяти под строки одной порции по умолчанию
+	defaultBufferSize = 64 << 20
+	// recordOverhead - оценка памяти на одну строку сверх ее длины: заголовок строки, номер, элемент слайса
+	recordOverhead = 64
+	// keyOverhead - оценка памяти на значение ключа сверх его длины
+	keyOverhead = 16
+	// maxMergeFanIn - сколько временных файлов сливается за один проход; остальные ждут следующего
+	maxMergeFanIn = 16
+)
+
+// record - строка, ее номер во входных данных и значения ключей. Номер нужен для устойчивости
+// сортировки при слиянии, ключи выделяются один раз при чтении, а не при каждом сравнении
+type record struct {
+	line string
+	seq  int64
+	keys []string
+}
+
+// lineReader - чтение строк без ограничения длины; "\n" и "\r\n" отрезаются, как в bufio.ScanLines
+type lineReader struct {
+	r *bufio.Reader
+	// csvSep - разделитель полей CSV: строка продолжается, пока не закрыта кавычка в поле; 0 - не CSV
+	csvSep rune
+	// count - число прочитанных строк
+	count int64
+}
+
+func newLineReader(src io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReader(src)}
+}
+
+// readLines - чтение строк src; с --csv запись может занимать несколько строк
+func (s *Config) readLines(src io.Reader) *lineReader {
+	lines := newLineReader(src)
+	if s.CSV {
+		lines.csvSep = s.splitter().sep
+	}
+	return lines
+}
+
+// next - следующая строка; false - данные закончились. Запись CSV с кавычкой, не закрытой до конца
+// данных, - ошибка: иначе она молча поглотила бы весь остаток файла
+func (lr *lineReader) next() (string, bool, error) {
+	line, ok, err := lr.readLine()
+	if lr.csvSep == 0 || !ok || err != nil {
+		return line, ok, err
+	}
+
+	// состояние кавычек сохраняется между строками: каждая строка записи разбирается один раз
+	state := csvState{fieldStart: true}
+	if !state.scan(line, lr.csvSep) {
+		return line, true, nil
+	}
+	startLine := lr.count
+	var record strings.Builder
+	record.WriteString(line)
+	for {
+		line, ok, err = lr.readLine()
+		if err != nil {
+			return "", false, err
+		}
+		if !ok {
+			return "", false, fmt.Errorf("line %d: unterminated quoted CSV field", startLine)
+		}
+		record.WriteString("\n")
+		record.WriteString(line)
+		// перевод строки внутри кавычек - часть поля, состояние не меняется
+		if !state.scan(line, lr.csvSep) {
+			return record.String(), true, nil
+		}
+	}
+}
+
+func (lr *lineReader) readLine() (string, bool, error) {
+	line, err := lr.r.ReadString('\n')
+	if err == io.EOF {
+		if line == "" {
+			return "", false, nil
+		}
+	} else if err != nil {
+		return "", false, err
+	}
+	lr.count++
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), true, nil
+}
+
+// recordSource - источник записей для слияния
+type recordSource interface {
+	next() (record, bool, error)
+}
+
+// sliceSource - записи отсортированной порции в памяти
+type sliceSource struct {
+	records []record
+}
+
+func (s *sliceSource) next() (record, bool, error) {
+	if len(s.records) == 0 {
+		return record{}, false, nil
+	}
+	rec := s.records[0]
+	s.records = s.records[1:]
+	return rec, true, nil
+}
+
+// runReader - чтение записей из временного файла: номер и длина строки в uvarint, затем строка
+type runReader struct {
+	r    *bufio.Reader
+	conf *Config
+}
+
+func (rr *runReader) next() (record, bool, error) {
+	seq, err := binary.ReadUvarint(rr.r)
+	if err == io.EOF {
+		return record{}, false, nil
+	} else if err != nil {
+		return record{}, false, err
+	}
+	size, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return record{}, false, unexpectedEOF(err)
+	}
+	line := make([]byte, size)
+	if _, err := io.ReadFull(rr.r, line); err != nil {
+		return record{}, false, unexpectedEOF(err)
+	}
+	return newRecord(string(line), int64(seq), rr.conf), true, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// runWriter - запись отсортированной порции во временный файл
+type runWriter struct {
+	w   *bufio.Writer
+	buf [2 * binary.MaxVarintLen64]byte
+}
+
+func (rw *runWriter) write(rec record) error {
+	n := binary.PutUvarint(rw.buf[:], uint64(rec.seq))
+	n += binary.PutUvarint(rw.buf[n:], uint64(len(rec.line)))
+	if _, err := rw.w.Write(rw.buf[:n]); err != nil {
+		return err
+	}
+	_, err := rw.w.WriteString(rec.line)
+	return err
+}
+
+// mergeItem - текущая запись источника
+type mergeItem struct {
+	rec record
+	src recordSource
+}
+
+// mergeHeap - куча текущих записей источников, вершина - наименьшая по lessRows
+type mergeHeap struct {
+	items []mergeItem
+	conf  *Config
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool { return lessRows(h.items[i].rec, h.items[j].rec, h.conf) }
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// merge - k-путевое слияние отсортированных источников в emit
+func merge(sources []recordSource, conf *Config, emit func(record) error) error {
+	h := &mergeHeap{conf: conf}
+	for _, src := range sources {
+		rec, ok, err := src.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.items = append(h.items, mergeItem{rec: rec, src: src})
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		top := &h.items[0]
+		if err := emit(top.rec); err != nil {
+			return err
+		}
+		rec, ok, err := top.src.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			top.rec = rec
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+// uniqueWriter - вывод отсортированных строк. С -u из каждой группы подряд идущих строк, равных
+// по ключам сравнения, выводится только первая, как в GNU sort; с --count перед строкой выводится
+// размер ее группы. Группа выводится, когда начинается следующая, последняя - в flush
+type uniqueWriter struct {
+	w    *bufio.Writer
+	conf *Config
+	// group - первая запись текущей группы, count - число записей в ней; 0 - группы еще нет
+	group record
+	count int64
+}
+
+func (u *uniqueWriter) write(rec record) error {
+	if !u.conf.Unique && !u.conf.Count {
+		return u.writeLine(rec.line)
+	}
+	if u.count > 0 && compareRecords(&u.group, &rec, u.conf) == 0 {
+		u.count++
+		return nil
+	}
+	if err := u.flush(); err != nil {
+		return err
+	}
+	u.group, u.count = rec, 1
+	return nil
+}
+
+// flush - вывод последней группы
+func (u *uniqueWriter) flush() error {
+	if u.count == 0 {
+		return nil
+	}
+	count := u.count
+	u.count = 0
+	if u.conf.Count {
+		if _, err := fmt.Fprintf(u.w, "%7d ", count); err != nil {
+			return err
+		}
+	}
+	return u.writeLine(u.group.line)
+}
+
+func (u *uniqueWriter) writeLine(line string) error {
+	if _, err := u.w.WriteString(line); err != nil {
+		return err
+	}
+	return u.w.WriteByte('\n')
+}
+
+// externalSorter - сортировка порциями ограниченного размера с выгрузкой во временные файлы
+type externalSorter struct {
+	conf *Config
+	// dir - временный каталог внутри conf.TempDir, создается при выгрузке первой порции
+	dir  string
+	runs []string
+}
+
+// externalSort - сортировка строк всех входных файлов с выводом в out; если все строки поместились
+// в буфер, временные файлы не создаются
+func externalSort(in []Input, out *bufio.Writer, conf *Config) error {
+	sorter := &externalSorter{conf: conf}
+	defer sorter.cleanup()
+
+	chunk, err := sorter.split(in)
+	if err != nil {
+		return err
+	}
+
+	output := &uniqueWriter{w: out, conf: conf}
+	if len(sorter.runs) == 0 {
+		sortRecords(chunk, conf)
+		err = merge([]recordSource{&sliceSource{records: chunk}}, conf, output.write)
+	} else if len(chunk) > 0 {
+		err = sorter.spill(chunk)
+	}
+	if err == nil && len(sorter.runs) > 0 {
+		err = sorter.mergeRuns(output.write)
+	}
+	if err != nil {
+		return err
+	}
+	return output.flush()
+}
+
+// split - чтение входных файлов по очереди порциями не больше conf.BufferSize; полные порции
+// сортируются и выгружаются, последняя порция возвращается без сортировки
+func (e *externalSorter) split(in []Input) ([]record, error) {
+	limit := e.conf.BufferSize
+	if limit <= 0 {
+		limit = defaultBufferSize
+	}
+
+	var chunk []record
+	var size int64
+	var seq int64
+	for _, input := range in {
+		lines := e.conf.readLines(input.R)
+		for ; ; seq++ {
+			line, ok, err := lines.next()
+			if err != nil {
+				return nil, readError(input.Name, err)
+			}
+			if !ok {
+				break
+			}
+
+			rec := newRecord(line, seq, e.conf)
+			chunk = append(chunk, rec)
+			size += int64(len(line)) + recordOverhead
+			for _, key := range rec.keys {
+				size += int64(len(key)) + keyOverhead
+			}
+			if size >= limit {
+				if err := e.spill(chunk); err != nil {
+					return nil, err
+				}
+				chunk, size = nil, 0
+			}
+		}
+	}
+
+	return chunk, nil
+}
+
+// spill - сортировка порции и запись во временный файл
+func (e *externalSorter) spill(chunk []record) error {
+	sortRecords(chunk, e.conf)
+	return e.writeRun(func(emit func(record) error) error {
+		for _, rec := range chunk {
+			if err := emit(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeRun - новый временный файл с записями, которые передает fill
+func (e *externalSorter) writeRun(fill func(emit func(record) error) error) error {
+	if e.dir == "" {
+		dir, err := os.MkdirTemp(e.conf.TempDir, "sort-")
+		if err != nil {
+			return fmt.Errorf("can not create temporary directory: %s", err.Error())
+		}
+		e.dir = dir
+	}
+
+	file, err := os.CreateTemp(e.dir, "run-")
+	if err != nil {
+		return fmt.Errorf("can not create temporary file: %s", err.Error())
+	}
+	defer file.Close()
+	e.runs = append(e.runs, file.Name())
+
+	w := &runWriter{w: bufio.NewWriter(file)}
+	if err := fill(w.write); err != nil {
+		return err
+	}
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// mergeRuns - слияние временных файлов: пока их больше maxMergeFanIn, соседние файлы сливаются
+// группами в новые временные файлы, порядок групп сохраняется
+func (e *externalSorter) mergeRuns(emit func(record) error) error {
+	for len(e.runs) > maxMergeFanIn {
+		runs := e.runs
+		e.runs = nil
+		for start := 0; start < len(runs); start += maxMergeFanIn {
+			end := start + maxMergeFanIn
+			if end > len(runs) {
+				end = len(runs)
+			}
+			group := runs[start:end]
+			err := e.writeRun(func(emit func(record) error) error {
+				return e.mergeFiles(group, emit)
+			})
+			if err != nil {
+				return err
+			}
+			for _, name := range group {
+				os.Remove(name)
+			}
+		}
+	}
+	return e.mergeFiles(e.runs, emit)
+}
+
+func (e *externalSorter) mergeFiles(names []string, emit func(record) error) error {
+	sources := make([]recordSource, 0, len(names))
+	for _, name := range names {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		sources = append(sources, &runReader{r: bufio.NewReader(file), conf: e.conf})
+	}
+	return merge(sources, e.conf, emit)
+}
+
+// cleanup - удаление временных файлов
+func (e *externalSorter) cleanup() {
+	if e.dir != "" {
+		os.RemoveAll(e.dir)
+	}
+}
@@ -0,0 +1,131 @@
+package sortutil
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// randomRows - count строк "слово число месяц" с повторами, чтобы были равные ключи
+func randomRows(count int) string {
+	months := []string{"янв", "фев", "мар", "апр", "май", "июн", "июл", "авг", "сен", "окт", "ноя", "дек", "???"}
+	words := []string{"laptop", "mouse", "data", "debian", "RedHat", "компьютер", "данные"}
+	random := rand.New(rand.NewSource(1))
+
+	var data strings.Builder
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&data, "%s %d %s\n", words[random.Intn(len(words))], random.Intn(200)-50, months[random.Intn(len(months))])
+	}
+	return data.String()
+}
+
+func TestExternalSortMatchesInMemory(t *testing.T) {
+	months := [12]string{"янв", "фев", "мар", "апр", "май", "июн", "июл", "авг", "сен", "окт", "ноя", "дек"}
+	rows := randomRows(3000)
+
+	testTable := []struct {
+		name string
+		sc   Config
+	}{
+		{name: "plain"},
+		{name: "reverse", sc: Config{Reverse: true}},
+		{name: "unique", sc: Config{Unique: true}},
+		{name: "numeric by column", sc: Config{Keys: mustParseKeys("2,2"), NumericSort: true}},
+		{name: "numeric by column reverse", sc: Config{Keys: mustParseKeys("2,2"), NumericSort: true, Reverse: true}},
+		{name: "string by columns", sc: Config{Keys: mustParseKeys("1,1", "2,2nr", "3.2")}},
+		{name: "month by column unique", sc: Config{Keys: mustParseKeys("3,3"), MonthSort: true, Unique: true, Months: months}},
+		{name: "count by column", sc: Config{Keys: mustParseKeys("1,1f"), Count: true}},
+		{name: "month by column reverse", sc: Config{Keys: mustParseKeys("3,3"), MonthSort: true, Reverse: true, Months: months}},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var expected strings.Builder
+			if err := Sort(strings.NewReader(rows), &expected, testingCase.sc); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+
+			// ~60 байт на строку с учетом recordOverhead: около 170 временных файлов и два прохода слияния
+			tempDir := t.TempDir()
+			external := testingCase.sc
+			external.BufferSize = 1 << 10
+			external.TempDir = tempDir
+			var result strings.Builder
+			if err := Sort(strings.NewReader(rows), &result, external); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if result.String() != expected.String() {
+				t.Errorf("external sort differs from in-memory sort")
+			}
+
+			if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+				t.Errorf("expected temporary files to be removed; got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestExternalSorterSpillsRuns(t *testing.T) {
+	conf := &Config{BufferSize: 100, TempDir: t.TempDir()}
+	sorter := &externalSorter{conf: conf}
+	defer sorter.cleanup()
+
+	input := strings.Repeat("line number one\nline number two\r\n", 10) + "last line without newline"
+	chunk, err := sorter.split([]Input{{Name: "-", R: strings.NewReader(input)}})
+	if err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+	if len(sorter.runs) < 5 {
+		t.Errorf("expected input larger than the buffer to be spilled; got %d runs", len(sorter.runs))
+	}
+	if err := sorter.spill(chunk); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var out strings.Builder
+	w := bufio.NewWriter(&out)
+	output := &uniqueWriter{w: w, conf: conf}
+	if err := sorter.mergeRuns(output.write); err != nil {
+		t.Fatalf(err.Error())
+	}
+	w.Flush()
+
+	expected := "last line without newline\n" + strings.Repeat("line number one\n", 10) + strings.Repeat("line number two\n", 10)
+	if out.String() != expected {
+		t.Errorf("expected result \n'%s';\n\ngot\n'%s'", expected, out.String())
+	}
+}
+
+func TestUniqueRows(t *testing.T) {
+	input := "b 2\na 1\nB 3\na 1\nc 1\nb 2\n"
+
+	testTable := []struct {
+		name string
+		sc   Config
+		out  string
+	}{
+		{name: "unique lines -u", sc: Config{Unique: true}, out: "B 3\na 1\nb 2\nc 1\n"},
+		{name: "first line of equal keys", sc: Config{Keys: mustParseKeys("2,2n"), Unique: true}, out: "a 1\nb 2\nB 3\n"},
+		{name: "first line of equal folded keys", sc: Config{Keys: mustParseKeys("1,1f"), Unique: true}, out: "a 1\nb 2\nc 1\n"},
+		{name: "count lines", sc: Config{Count: true}, out: "      1 B 3\n      2 a 1\n      2 b 2\n      1 c 1\n"},
+		{
+			name: "count equal keys reverse", sc: Config{Keys: mustParseKeys("1,1fr"), Count: true, Unique: true},
+			out: "      1 c 1\n      3 b 2\n      2 a 1\n",
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Sort(strings.NewReader(input), &out, testingCase.sc); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if out.String() != testingCase.out {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", testingCase.out, out.String())
+			}
+		})
+	}
+}
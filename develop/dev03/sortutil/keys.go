@@ -0,0 +1,356 @@
+package sortutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Ключи сортировки в формате GNU sort: -k POS1[,POS2], POS = F[.C][OPTS].
+F - номер поля с 1, C - номер символа в поле с 1; в POS2 C = 0 или без C - до конца поля,
+без POS2 - до конца строки. Поле без -t - непустая последовательность непробельных символов
+вместе с предшествующими пробелами и табуляциями; с -t SEP - текст между разделителями, пустые поля
+допустимы. С --csv поле в кавычках может содержать разделители, кавычки ("") и переводы строк,
+ключ сравнивается по значению поля без кавычек; кавычка, не закрытая до конца данных, - ошибка.
+Символы считаются в рунах, ключ из нескольких полей включает разделители между ними.
+
+Модификаторы OPTS:
+b - пропускать пробелы в начале поля (у POS1 - для начала ключа, у POS2 - для конца)
+d - словарный порядок: сравниваются только буквы, цифры и пробелы
+f - не различать регистр
+n - по числовому значению: "-1.5" < "2" < "10"
+g - по числу с плавающей точкой: "1e3", "inf", "nan"
+M - по названию месяца
+h - по числу с суффиксом: "2K" < "1.5M" < "1G", см. compareHuman
+V - по номеру версии: "1.2.10" > "1.2.9"
+R - случайный порядок групп равных ключей, см. random.go
+r - в обратном порядке
+
+Ключ без модификаторов наследует глобальные флаги -b, -d, -f, -n, -g, -h, -M, -V, -R, -r.
+Модификатор d несовместим с n, g, h, M, V, R; d применяется только к текстовым ключам,
+f - к текстовым и R. Ключи сравниваются по очереди, следующий ключ разрешает равенство
+предыдущего; строки, равные по всем ключам, как в GNU sort сравниваются целиком побайтно
+(с -r - в обратном порядке), а с -s, -u и --count сохраняют порядок ввода.
+
+Пример: -k2,2n -k1,1r -k3.4,3.8
+*/
+
+// ordering - способ сравнения значений ключа
+type ordering int
+
+const (
+	orderText ordering = iota
+	orderNumeric
+	orderMonth
+	orderHuman
+	orderGeneral
+	orderVersion
+	orderRandom
+)
+
+// orderModifiers - модификаторы, задающие способ сравнения; одновременно допустим только один, и не вместе с d
+var orderModifiers = map[byte]ordering{
+	'n': orderNumeric, 'M': orderMonth, 'h': orderHuman, 'g': orderGeneral, 'V': orderVersion, 'R': orderRandom,
+}
+
+// keyOptions - параметры сравнения ключа
+type keyOptions struct {
+	order      ordering
+	fold       bool
+	dictionary bool
+	reverse    bool
+	// blanksStart, blanksEnd - модификатор b у начала и конца ключа
+	blanksStart bool
+	blanksEnd   bool
+}
+
+// KeySpec - ключ сортировки -k POS1[,POS2][OPTS]; создается ParseKeySpec, нулевое значение
+// не является ключом и отклоняется Config.Validate и сравнителями Config
+type KeySpec struct {
+	startField, startChar int
+	// endField - 0, если POS2 не задан; endChar - 0, если ключ идет до конца поля
+	endField, endChar int
+	opts              keyOptions
+	// inherit - у ключа нет модификаторов, используются глобальные флаги
+	inherit bool
+}
+
+// ParseKeySpec - разбор ключа "-k"
+func ParseKeySpec(spec string) (KeySpec, error) {
+	key := KeySpec{inherit: true}
+	// orders - все модификаторы порядка, для проверки на несовместимость
+	var orders []byte
+
+	rest := spec
+	var err error
+	if key.startField, rest, err = parseFieldCount(rest); err != nil {
+		return key, fmt.Errorf("invalid number at field start: invalid count at start of '%s'", spec)
+	}
+	if key.startField == 0 {
+		return key, fmt.Errorf("field number is zero: invalid field specification '%s'", spec)
+	}
+	key.startChar = 1
+	if strings.HasPrefix(rest, ".") {
+		if key.startChar, rest, err = parseFieldCount(rest[1:]); err != nil {
+			return key, fmt.Errorf("invalid number after '.': invalid count at start of '%s'", rest)
+		}
+		if key.startChar == 0 {
+			return key, fmt.Errorf("character offset is zero: invalid field specification '%s'", spec)
+		}
+	}
+	rest = key.parseModifiers(rest, &key.opts.blanksStart, &orders)
+
+	if strings.HasPrefix(rest, ",") {
+		if key.endField, rest, err = parseFieldCount(rest[1:]); err != nil {
+			return key, fmt.Errorf("invalid number after ',': invalid count at start of '%s'", rest)
+		}
+		if key.endField == 0 {
+			return key, fmt.Errorf("field number is zero: invalid field specification '%s'", spec)
+		}
+		if strings.HasPrefix(rest, ".") {
+			if key.endChar, rest, err = parseFieldCount(rest[1:]); err != nil {
+				return key, fmt.Errorf("invalid number after '.': invalid count at start of '%s'", rest)
+			}
+		}
+		rest = key.parseModifiers(rest, &key.opts.blanksEnd, &orders)
+	}
+
+	if rest != "" {
+		return key, fmt.Errorf("stray character in field spec: invalid field specification '%s'", spec)
+	}
+	if len(orders) > 1 {
+		return key, fmt.Errorf("options '-%s' are incompatible", orders)
+	}
+	return key, nil
+}
+
+// parseFieldCount - число в начале строки и остаток строки
+func parseFieldCount(str string) (int, string, error) {
+	end := 0
+	for end < len(str) && str[end] >= '0' && str[end] <= '9' {
+		end++
+	}
+	count, err := strconv.Atoi(str[:end])
+	return count, str[end:], err
+}
+
+// parseModifiers - модификаторы после POS1 или POS2; b относится к той позиции, после которой указан
+func (k *KeySpec) parseModifiers(str string, blanks *bool, orders *[]byte) string {
+	for ; str != ""; str = str[1:] {
+		switch modifier := str[0]; modifier {
+		case 'b':
+			*blanks = true
+		case 'd':
+			if !k.opts.dictionary {
+				*orders = append(*orders, modifier)
+			}
+			k.opts.dictionary = true
+		case 'f':
+			k.opts.fold = true
+		case 'r':
+			k.opts.reverse = true
+		default:
+			order, ok := orderModifiers[modifier]
+			if !ok {
+				return str
+			}
+			if k.opts.order != order {
+				*orders = append(*orders, modifier)
+			}
+			k.opts.order = order
+		}
+		k.inherit = false
+	}
+	return str
+}
+
+// options - параметры сравнения с учетом глобальных флагов
+func (k *KeySpec) options(global keyOptions) keyOptions {
+	if k.inherit {
+		return global
+	}
+	return k.opts
+}
+
+// extract - часть строки, выделенная ключом; пустая, если конец ключа раньше начала.
+// Как в GNU sort, смещение символа может выходить за конец поля, но не за конец строки
+func (k *KeySpec) extract(layout fieldLayout, opts keyOptions) string {
+	text, fields := layout.text, layout.fields
+	if k.startField > len(fields) {
+		return ""
+	}
+	start := fields[k.startField-1].start
+	if opts.blanksStart {
+		start = skipBlanks(text, start)
+	}
+	start = advanceRunes(text, start, k.startChar-1)
+
+	// без POS2 или за последним полем - до конца строки
+	end := len(text)
+	if k.endField != 0 && k.endField <= len(fields) {
+		field := fields[k.endField-1]
+		if k.endChar == 0 {
+			end = field.end
+		} else {
+			end = field.start
+			if opts.blanksEnd {
+				end = skipBlanks(text, end)
+			}
+			end = advanceRunes(text, end, k.endChar)
+		}
+	}
+
+	if end <= start {
+		return ""
+	}
+	return text[start:end]
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func skipBlanks(line string, pos int) int {
+	for pos < len(line) && isBlank(line[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// fieldSpan - границы поля в байтах
+type fieldSpan struct {
+	start, end int
+}
+
+// fieldLayout - текст, из которого выделяются ключи, и границы полей в нем. Без --csv текст - сама строка,
+// с --csv - значения полей без кавычек через разделитель
+type fieldLayout struct {
+	text   string
+	fields []fieldSpan
+}
+
+// fieldSplitter - разбиение строки на поля
+type fieldSplitter struct {
+	// sep - разделитель полей; 0 - переход от пробельных символов к непробельным
+	sep rune
+	csv bool
+}
+
+// layout - поля строки; без разделителя поле включает предшествующие пробелы
+func (f fieldSplitter) layout(line string) fieldLayout {
+	if f.csv {
+		return joinFields(splitCSV(line, f.sep), string(f.sep))
+	}
+	if f.sep != 0 {
+		layout := fieldLayout{text: line}
+		sep := string(f.sep)
+		for start := 0; ; {
+			end := strings.Index(line[start:], sep)
+			if end < 0 {
+				layout.fields = append(layout.fields, fieldSpan{start: start, end: len(line)})
+				return layout
+			}
+			layout.fields = append(layout.fields, fieldSpan{start: start, end: start + end})
+			start += end + len(sep)
+		}
+	}
+
+	layout := fieldLayout{text: line}
+	for pos := 0; pos < len(line); {
+		start := pos
+		pos = skipBlanks(line, pos)
+		for pos < len(line) && !isBlank(line[pos]) {
+			pos++
+		}
+		layout.fields = append(layout.fields, fieldSpan{start: start, end: pos})
+	}
+	return layout
+}
+
+// joinFields - значения полей через разделитель
+func joinFields(values []string, sep string) fieldLayout {
+	layout := fieldLayout{text: strings.Join(values, sep), fields: make([]fieldSpan, len(values))}
+	pos := 0
+	for i, value := range values {
+		layout.fields[i] = fieldSpan{start: pos, end: pos + len(value)}
+		pos += len(value) + len(sep)
+	}
+	return layout
+}
+
+// splitCSV - значения полей записи CSV (RFC 4180): кавычки вокруг поля снимаются, "" внутри - кавычка.
+// Кавычка в середине поля без кавычек считается обычным символом
+func splitCSV(record string, sep rune) []string {
+	var fields []string
+	var field strings.Builder
+	quoted, fieldStart := false, true
+	for pos := 0; pos < len(record); {
+		symbol, size := utf8.DecodeRuneInString(record[pos:])
+		pos += size
+
+		switch {
+		case quoted && symbol == '"':
+			if strings.HasPrefix(record[pos:], `"`) {
+				field.WriteRune('"')
+				pos++
+			} else {
+				quoted = false
+			}
+		case quoted:
+			field.WriteRune(symbol)
+		case symbol == '"' && fieldStart:
+			quoted = true
+		case symbol == sep:
+			fields = append(fields, field.String())
+			field.Reset()
+			fieldStart = true
+			continue
+		default:
+			field.WriteRune(symbol)
+		}
+		fieldStart = false
+	}
+	return append(fields, field.String())
+}
+
+// csvState - состояние разбора CSV по частям, как в splitCSV, но без выделения полей:
+// по нему строки записи с переводами строк внутри кавычек читаются за один проход
+type csvState struct {
+	quoted, fieldStart bool
+}
+
+// scan - разбор очередной части записи; true - кавычка поля осталась незакрытой
+func (st *csvState) scan(text string, sep rune) bool {
+	for pos := 0; pos < len(text); {
+		symbol, size := utf8.DecodeRuneInString(text[pos:])
+		pos += size
+
+		switch {
+		case st.quoted && symbol == '"':
+			if strings.HasPrefix(text[pos:], `"`) {
+				pos++
+			} else {
+				st.quoted = false
+			}
+		case st.quoted:
+		case symbol == '"' && st.fieldStart:
+			st.quoted = true
+		case symbol == sep:
+			st.fieldStart = true
+			continue
+		}
+		st.fieldStart = false
+	}
+	return st.quoted
+}
+
+// advanceRunes - смещение после count рун, не дальше конца строки
+func advanceRunes(line string, pos, count int) int {
+	for ; count > 0 && pos < len(line); count-- {
+		_, size := utf8.DecodeRuneInString(line[pos:])
+		pos += size
+	}
+	return pos
+}
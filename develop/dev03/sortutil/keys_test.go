@@ -0,0 +1,289 @@
+package sortutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// mustParseKeys - ключи для таблиц тестов
+func mustParseKeys(specs ...string) []KeySpec {
+	keys := make([]KeySpec, 0, len(specs))
+	for _, spec := range specs {
+		key, err := ParseKeySpec(spec)
+		if err != nil {
+			panic(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestParseKeySpec(t *testing.T) {
+	testTable := []struct {
+		spec      string
+		out       KeySpec
+		haveError bool
+	}{
+		{spec: "2", out: KeySpec{startField: 2, startChar: 1, inherit: true}},
+		{spec: "2,2", out: KeySpec{startField: 2, startChar: 1, endField: 2, inherit: true}},
+		{spec: "3.4,3.8", out: KeySpec{startField: 3, startChar: 4, endField: 3, endChar: 8, inherit: true}},
+		{spec: "2,2n", out: KeySpec{startField: 2, startChar: 1, endField: 2, opts: keyOptions{order: orderNumeric}}},
+		{spec: "1,1r", out: KeySpec{startField: 1, startChar: 1, endField: 1, opts: keyOptions{reverse: true}}},
+		{spec: "1b,1", out: KeySpec{startField: 1, startChar: 1, endField: 1, opts: keyOptions{blanksStart: true}}},
+		{spec: "1,1.3b", out: KeySpec{startField: 1, startChar: 1, endField: 1, endChar: 3, opts: keyOptions{blanksEnd: true}}},
+		{spec: "4Mr", out: KeySpec{startField: 4, startChar: 1, opts: keyOptions{order: orderMonth, reverse: true}}},
+		{spec: "1.2f,1V", out: KeySpec{startField: 1, startChar: 2, endField: 1, opts: keyOptions{order: orderVersion, fold: true}}},
+		{spec: "2df", out: KeySpec{startField: 2, startChar: 1, opts: keyOptions{dictionary: true, fold: true}}},
+		{spec: "1fn", out: KeySpec{startField: 1, startChar: 1, opts: keyOptions{order: orderNumeric, fold: true}}},
+		{spec: "5h,5h", out: KeySpec{startField: 5, startChar: 1, endField: 5, opts: keyOptions{order: orderHuman}}},
+		{spec: "1,2.0", out: KeySpec{startField: 1, startChar: 1, endField: 2, inherit: true}},
+		{spec: "", haveError: true},
+		{spec: "0", haveError: true},
+		{spec: "1.0", haveError: true},
+		{spec: "1,0", haveError: true},
+		{spec: "a", haveError: true},
+		{spec: "1x", haveError: true},
+		{spec: "1,", haveError: true},
+		{spec: "1,2.", haveError: true},
+		{spec: "1n,1M", haveError: true},
+		{spec: "1hV", haveError: true},
+		{spec: "1nR", haveError: true},
+		{spec: "1d,1n", haveError: true},
+		{spec: "1Vd", haveError: true},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.spec, func(t *testing.T) {
+			result, err := ParseKeySpec(testingCase.spec)
+			if testingCase.haveError {
+				if err == nil {
+					t.Errorf("expected error, but err is nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if result != testingCase.out {
+				t.Errorf("expected %+v; got %+v", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestKeyExtract(t *testing.T) {
+	line := "  alpha  бета\tgamma.delta   "
+	testTable := []struct {
+		spec   string
+		global keyOptions
+		out    string
+	}{
+		{spec: "1", out: "  alpha  бета\tgamma.delta   "},
+		{spec: "1,1", out: "  alpha"},
+		{spec: "2,2", out: "  бета"},
+		{spec: "2b,2", out: "бета"},
+		{spec: "2,2", global: keyOptions{blanksStart: true}, out: "бета"},
+		{spec: "2.2,2.4", out: " бе"},
+		{spec: "2.2b,2.4b", out: "ета"},
+		{spec: "3.7", out: ".delta   "},
+		{spec: "3.1,3.5", out: "\tgamm"},
+		{spec: "1,3", out: "  alpha  бета\tgamma.delta"},
+		{spec: "4", out: "   "},
+		{spec: "5", out: ""},
+		{spec: "2,1", out: ""},
+		{spec: "1.30", out: ""},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.spec, func(t *testing.T) {
+			key := mustParseKeys(testingCase.spec)[0]
+			result := key.extract(fieldSplitter{}.layout(line), key.options(testingCase.global))
+			if result != testingCase.out {
+				t.Errorf("expected key %q; got %q", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestSortByKeys(t *testing.T) {
+	input := strings.Join([]string{
+		"ivanov 30 msk-0042",
+		"petrov 25 spb-0007",
+		"Ivanov 30 msk-0100",
+		"sidorov 25 ekb-0042",
+		"petrov 30 msk-0042",
+	}, "\n")
+
+	testTable := []struct {
+		name string
+		sc   Config
+		out  []string
+	}{
+		{
+			name: "numeric key, then reversed name",
+			sc:   Config{Keys: mustParseKeys("2,2n", "1,1r")},
+			out:  []string{"sidorov 25 ekb-0042", "petrov 25 spb-0007", "petrov 30 msk-0042", "ivanov 30 msk-0042", "Ivanov 30 msk-0100"},
+		},
+		{
+			name: "character offsets without leading blanks",
+			sc:   Config{Keys: mustParseKeys("3.5b,3.8bn", "3.1b,3.3b")},
+			out:  []string{"petrov 25 spb-0007", "sidorov 25 ekb-0042", "ivanov 30 msk-0042", "petrov 30 msk-0042", "Ivanov 30 msk-0100"},
+		},
+		{
+			name: "fold case keeps input order of equal keys with -s",
+			sc:   Config{Keys: mustParseKeys("1,1f"), Stable: true},
+			out:  []string{"ivanov 30 msk-0042", "Ivanov 30 msk-0100", "petrov 25 spb-0007", "petrov 30 msk-0042", "sidorov 25 ekb-0042"},
+		},
+		{
+			// как в GNU sort: строки, равные по ключам, сравниваются целиком побайтно
+			name: "equal keys compared as whole lines",
+			sc:   Config{Keys: mustParseKeys("1,1f")},
+			out:  []string{"Ivanov 30 msk-0100", "ivanov 30 msk-0042", "petrov 25 spb-0007", "petrov 30 msk-0042", "sidorov 25 ekb-0042"},
+		},
+		{
+			name: "global flags apply to keys without modifiers",
+			sc:   Config{Keys: mustParseKeys("2,2", "1,1f"), NumericSort: true, Reverse: true},
+			out:  []string{"ivanov 30 msk-0042", "Ivanov 30 msk-0100", "petrov 30 msk-0042", "petrov 25 spb-0007", "sidorov 25 ekb-0042"},
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Sort(strings.NewReader(input), &out, testingCase.sc); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			expected := strings.Join(testingCase.out, "\n") + "\n"
+			if out.String() != expected {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", expected, out.String())
+			}
+		})
+	}
+}
+
+func TestFieldLayout(t *testing.T) {
+	testTable := []struct {
+		name     string
+		splitter fieldSplitter
+		in       string
+		out      []string
+	}{
+		{name: "blanks", in: "  a bb\tc  ", out: []string{"  a", " bb", "\tc", "  "}},
+		{name: "empty line", in: ""},
+		{name: "separator", splitter: fieldSplitter{sep: ':'}, in: "root:x::0", out: []string{"root", "x", "", "0"}},
+		{name: "multibyte separator", splitter: fieldSplitter{sep: '│'}, in: "а│б│", out: []string{"а", "б", ""}},
+		{name: "csv", splitter: fieldSplitter{sep: ',', csv: true}, in: `1,"Smith, John","say ""hi""",`, out: []string{"1", "Smith, John", `say "hi"`, ""}},
+		{name: "csv newline in quotes", splitter: fieldSplitter{sep: ',', csv: true}, in: "\"a\nb\",c", out: []string{"a\nb", "c"}},
+		{name: "csv quote inside field", splitter: fieldSplitter{sep: ',', csv: true}, in: `5" disk,x`, out: []string{`5" disk`, "x"}},
+		{name: "tsv", splitter: fieldSplitter{sep: '\t', csv: true}, in: "\"a\tb\"\tc", out: []string{"a\tb", "c"}},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			layout := testingCase.splitter.layout(testingCase.in)
+			var fields []string
+			for _, field := range layout.fields {
+				fields = append(fields, layout.text[field.start:field.end])
+			}
+			if strings.Join(fields, "|") != strings.Join(testingCase.out, "|") || len(fields) != len(testingCase.out) {
+				t.Errorf("expected fields %q; got %q", testingCase.out, fields)
+			}
+		})
+	}
+}
+
+func TestSortBySeparatedFields(t *testing.T) {
+	testTable := []struct {
+		name string
+		sc   Config
+		in   string
+		out  string
+	}{
+		{
+			name: "separator with empty fields",
+			sc:   Config{Separator: ':', Keys: mustParseKeys("3,3n")},
+			in:   "root:x:0:0\nnobody:x:65534:65534\ndaemon:x:1:1\nbroken:x::\n",
+			// пустое поле равно нулю, равные по ключу строки сравниваются целиком
+			out: "broken:x::\nroot:x:0:0\ndaemon:x:1:1\nnobody:x:65534:65534\n",
+		},
+		{
+			name: "key spans fields with separator",
+			sc:   Config{Separator: ':', Keys: mustParseKeys("2,3")},
+			in:   "a:b:c\nb:b:a\nc:a:z\n",
+			out:  "c:a:z\nb:b:a\na:b:c\n",
+		},
+		{
+			name: "character offset past the field end",
+			sc:   Config{Keys: mustParseKeys("1.3")},
+			in:   "ab zz\nab ay\n",
+			out:  "ab ay\nab zz\n",
+		},
+		{
+			name: "csv quoted separators and newlines",
+			sc:   Config{CSV: true, Keys: mustParseKeys("2,2")},
+			in:   "1,\"Smith, John\",x\n2,Adams,\"multi\nline\"\n3,\"\"\"Quoted\"\" Name\",y\n",
+			out:  "3,\"\"\"Quoted\"\" Name\",y\n2,Adams,\"multi\nline\"\n1,\"Smith, John\",x\n",
+		},
+		{
+			name: "csv numeric column",
+			sc:   Config{CSV: true, Keys: mustParseKeys("3,3nr")},
+			in:   "id,name,amount\n1,\"a,b\",10\n2,c,\"9\"\n3,d,100\n",
+			out:  "3,d,100\n1,\"a,b\",10\n2,c,\"9\"\nid,name,amount\n",
+		},
+		{
+			name: "csv quote inside unquoted field does not continue the record",
+			sc:   Config{CSV: true, Keys: mustParseKeys("1,1")},
+			in:   "b,5\" disk\na,x\n",
+			out:  "a,x\nb,5\" disk\n",
+		},
+		{
+			name: "tsv",
+			sc:   Config{CSV: true, Separator: '\t', Keys: mustParseKeys("2")},
+			in:   "1\t\"b\tb\"\n2\ta\n",
+			out:  "2\ta\n1\t\"b\tb\"\n",
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Sort(strings.NewReader(testingCase.in), &out, testingCase.sc); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if out.String() != testingCase.out {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", testingCase.out, out.String())
+			}
+		})
+	}
+}
+
+func TestCSVUnterminatedQuote(t *testing.T) {
+	// незакрытая кавычка в первой строке большого файла: каждая строка разбирается один раз,
+	// а не вся накопленная запись заново, и в конце данных - ошибка вместо одной гигантской записи
+	var in strings.Builder
+	in.WriteString("\"unclosed,1\n")
+	for i := 0; i < 200000; i++ {
+		fmt.Fprintf(&in, "row %d,%d\n", i, i)
+	}
+
+	var out strings.Builder
+	err := Sort(strings.NewReader(in.String()), &out, Config{CSV: true, Keys: mustParseKeys("2,2n")})
+	if err == nil {
+		t.Fatalf("expected error, but err is nil")
+	}
+	if expected := "can not read file '-': line 1: unterminated quoted CSV field"; err.Error() != expected {
+		t.Errorf("expected err.Error() == '%s'; got '%s'", expected, err.Error())
+	}
+
+	// кавычка закрывается через много строк - одна запись, номер следующей строки считается верно
+	lines := newLineReader(strings.NewReader("\"a\n" + strings.Repeat("b\n", 100000) + "c\",1\nd,2\n"))
+	lines.csvSep = ','
+	record, ok, err := lines.next()
+	if err != nil || !ok || !strings.HasSuffix(record, "\nc\",1") || strings.Count(record, "\n") != 100001 {
+		t.Fatalf("expected one record of 100002 lines; got %d lines, %v, %v", strings.Count(record, "\n")+1, ok, err)
+	}
+	if record, _, _ = lines.next(); record != "d,2" || lines.count != 100003 {
+		t.Errorf("expected 'd,2' at line 100003; got '%s' at line %d", record, lines.count)
+	}
+}
@@ -0,0 +1,89 @@
+package sortutil
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+/*
+Названия месяцев для -M. Значение ключа - первое слово после пробелов, регистр не важен:
+"Jan", "JANUARY", "янв", "Января". Неизвестные значения идут раньше января, все равны между собой.
+
+Без -months распознаются английские и русские названия. -months задает таблицу:
+локаль ("en", "ru", "ru_RU.UTF-8") или 12 месяцев через запятую, варианты месяца через '|':
+-months "sty|stycznia,lut|lutego,..."
+*/
+
+// englishMonths, russianMonths - встроенные таблицы: сокращения, полные названия и родительный падеж
+var (
+	englishMonths = [12]string{
+		"jan|january", "feb|february", "mar|march", "apr|april", "may", "jun|june",
+		"jul|july", "aug|august", "sep|sept|september", "oct|october", "nov|november", "dec|december",
+	}
+	russianMonths = [12]string{
+		"янв|январь|января", "фев|февраль|февраля", "мар|март|марта", "апр|апрель|апреля",
+		"май|мая", "июн|июнь|июня", "июл|июль|июля", "авг|август|августа",
+		"сен|сентябрь|сентября", "окт|октябрь|октября", "ноя|ноябрь|ноября", "дек|декабрь|декабря",
+	}
+)
+
+// ParseMonths - таблица месяцев -months: локаль или 12 месяцев через запятую
+func ParseMonths(value string) ([12]string, error) {
+	var months [12]string
+	locale := value
+	if end := strings.IndexAny(value, "_-."); end >= 0 {
+		locale = value[:end]
+	}
+	switch strings.ToLower(locale) {
+	case "en", "english", "c", "posix":
+		return englishMonths, nil
+	case "ru", "russian":
+		return russianMonths, nil
+	}
+
+	entries := strings.Split(value, ",")
+	if len(entries) != len(months) {
+		return months, fmt.Errorf("invalid month table '%s': expected locale or 12 comma-separated months", value)
+	}
+	for i, entry := range entries {
+		if strings.Trim(entry, " |") == "" {
+			return months, fmt.Errorf("invalid month table '%s': month %d has no names", value, i+1)
+		}
+		months[i] = entry
+	}
+	return months, nil
+}
+
+// monthKey - номер месяца как значение ключа для побайтного сравнения: "\x00" - неизвестное
+// значение, "\x01" - январь, ..., "\x0c" - декабрь
+func (s *Config) monthKey(value string) string {
+	return string(rune(s.monthNumber(value)))
+}
+
+// monthNumber - номер месяца от 1 до 12; 0 - значение не похоже на месяц
+func (s *Config) monthNumber(value string) int {
+	value = strings.TrimLeft(value, " \t")
+	end := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end >= 0 {
+		value = value[:end]
+	}
+	if value == "" {
+		return 0
+	}
+
+	tables := [][12]string{s.Months}
+	if s.Months == ([12]string{}) {
+		tables = [][12]string{englishMonths, russianMonths}
+	}
+	for _, table := range tables {
+		for i, names := range table {
+			for _, name := range strings.Split(names, "|") {
+				if strings.EqualFold(value, strings.TrimSpace(name)) {
+					return i + 1
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,125 @@
+package sortutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMonthNumber(t *testing.T) {
+	polish, err := ParseMonths("sty|stycznia,lut,mar,kwi,maj,cze,lip,sie,wrz,paź,lis,gru|grudnia")
+	if err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+
+	testTable := []struct {
+		in     string
+		months [12]string
+		out    int
+	}{
+		{in: "Jan", out: 1},
+		{in: "FEBRUARY", out: 2},
+		{in: "  sept", out: 9},
+		{in: "Dec. 31", out: 12},
+		{in: "may", out: 5},
+		{in: "янв", out: 1},
+		{in: "Мая", out: 5},
+		{in: "\tдекабрь 2021", out: 12},
+		{in: "Janu", out: 0},
+		{in: "marker", out: 0},
+		{in: "12", out: 0},
+		{in: "", out: 0},
+		{in: "ноя", months: russianMonths, out: 11},
+		{in: "nov", months: russianMonths, out: 0},
+		{in: "Grudnia", months: polish, out: 12},
+		{in: "jan", months: polish, out: 0},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.in, func(t *testing.T) {
+			s := Config{Months: testingCase.months}
+			if result := s.monthNumber(testingCase.in); result != testingCase.out {
+				t.Errorf("expected %d; got %d", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestParseMonths(t *testing.T) {
+	testTable := []struct {
+		in        string
+		out       [12]string
+		haveError bool
+	}{
+		{in: "en", out: englishMonths},
+		{in: "en_US.UTF-8", out: englishMonths},
+		{in: "ru_RU.UTF-8", out: russianMonths},
+		{in: "C", out: englishMonths},
+		{in: "1,2,3,4,5,6,7,8,9,10,11,12", out: [12]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}},
+		{in: "", haveError: true},
+		{in: "de", haveError: true},
+		{in: "jan,feb,mar", haveError: true},
+		{in: "1,2,3,4,5,6,7,8,9,10,11,|", haveError: true},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.in, func(t *testing.T) {
+			result, err := ParseMonths(testingCase.in)
+			if testingCase.haveError {
+				if err == nil {
+					t.Errorf("expected error, but err is nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if result != testingCase.out {
+				t.Errorf("expected %v; got %v", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestSortByMonthNames(t *testing.T) {
+	input := strings.Join([]string{"March", "???", "янв", "december", "Feb", "", "Февраля", "smarch"}, "\n")
+
+	testTable := []struct {
+		name string
+		sc   Config
+		out  []string
+	}{
+		{
+			name: "unknown values first",
+			sc:   Config{MonthSort: true},
+			out:  []string{"", "???", "smarch", "янв", "Feb", "Февраля", "March", "december"},
+		},
+		{
+			name: "stable keeps input order of equal months",
+			sc:   Config{MonthSort: true, Stable: true},
+			out:  []string{"???", "", "smarch", "янв", "Feb", "Февраля", "March", "december"},
+		},
+		{
+			name: "reverse puts unknown values last",
+			sc:   Config{MonthSort: true, Reverse: true},
+			out:  []string{"december", "March", "Февраля", "Feb", "янв", "smarch", "???", ""},
+		},
+		{
+			name: "english table only",
+			sc:   Config{MonthSort: true, Months: englishMonths},
+			out:  []string{"", "???", "smarch", "Февраля", "янв", "Feb", "March", "december"},
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Sort(strings.NewReader(input), &out, testingCase.sc); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			expected := strings.Join(testingCase.out, "\n") + "\n"
+			if out.String() != expected {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", expected, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package sortutil
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+)
+
+const (
+	// maxDefaultParallel - предел числа горутин по умолчанию, как в GNU sort
+	maxDefaultParallel = 8
+	// minParallelPart - меньше записей на горутину не выделяется: запуск и слияние дороже выигрыша
+	minParallelPart = 4096
+)
+
+// DefaultParallel - число горутин сортировки по умолчанию: по числу процессоров, не больше maxDefaultParallel
+func DefaultParallel() int {
+	if n := runtime.NumCPU(); n < maxDefaultParallel {
+		return n
+	}
+	return maxDefaultParallel
+}
+
+// sortRecords - сортировка порции по lessRows: порция делится на части по числу горутин, части
+// сортируются параллельно и попарно сливаются, тоже параллельно. lessRows различает любые две записи
+// по номеру, поэтому результат совпадает с последовательной сортировкой байт в байт
+func sortRecords(records []record, conf *Config) {
+	workers := conf.Parallel
+	if most := len(records) / minParallelPart; workers > most {
+		workers = most
+	}
+	if workers <= 1 {
+		sortSequential(records, conf)
+		return
+	}
+
+	parts := make([][]record, 0, workers)
+	for i := 0; i < workers; i++ {
+		parts = append(parts, records[i*len(records)/workers:(i+1)*len(records)/workers])
+	}
+	var wg sync.WaitGroup
+	for _, part := range parts {
+		wg.Add(1)
+		go func(part []record) {
+			defer wg.Done()
+			sortSequential(part, conf)
+		}(part)
+	}
+	wg.Wait()
+
+	// части сливаются попарно из records в buf и обратно, пока не останется одна
+	buf := make([]record, len(records))
+	dst := buf
+	for len(parts) > 1 {
+		merged := make([][]record, 0, (len(parts)+1)/2)
+		offset := 0
+		for i := 0; i < len(parts); i += 2 {
+			if i+1 == len(parts) {
+				out := dst[offset : offset+len(parts[i])]
+				copy(out, parts[i])
+				merged = append(merged, out)
+				break
+			}
+			out := dst[offset : offset+len(parts[i])+len(parts[i+1])]
+			wg.Add(1)
+			go func(a, b, out []record) {
+				defer wg.Done()
+				mergePair(a, b, out, conf)
+			}(parts[i], parts[i+1], out)
+			merged = append(merged, out)
+			offset += len(out)
+		}
+		wg.Wait()
+
+		parts = merged
+		if &dst[0] == &buf[0] {
+			dst = records
+		} else {
+			dst = buf
+		}
+	}
+	if &parts[0][0] != &records[0] {
+		copy(records, parts[0])
+	}
+}
+
+func sortSequential(records []record, conf *Config) {
+	sort.Slice(records, func(i, j int) bool {
+		return lessRows(records[i], records[j], conf)
+	})
+}
+
+// mergePair - слияние отсортированных a и b в out; при равенстве первой идет запись из a
+func mergePair(a, b, out []record, conf *Config) {
+	i, j := 0, 0
+	for k := range out {
+		if j == len(b) || i < len(a) && !lessRows(b[j], a[i], conf) {
+			out[k] = a[i]
+			i++
+		} else {
+			out[k] = b[j]
+			j++
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package sortutil
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// randomRecords - count записей "слово число" с повторами ключей
+func randomRecords(count int, conf *Config) []record {
+	words := []string{"laptop", "mouse", "data", "debian", "RedHat", "компьютер", "данные", "ёж", "Ежевика"}
+	random := rand.New(rand.NewSource(1))
+	records := make([]record, count)
+	for i := range records {
+		line := fmt.Sprintf("%s %d", words[random.Intn(len(words))], random.Intn(1000)-500)
+		records[i] = newRecord(line, int64(i), conf)
+	}
+	return records
+}
+
+func TestSortRecordsParallel(t *testing.T) {
+	testTable := []struct {
+		name string
+		sc   Config
+	}{
+		{name: "plain"},
+		{name: "numeric by column reverse", sc: Config{Keys: mustParseKeys("2,2"), NumericSort: true, Reverse: true}},
+		{name: "fold by column then numeric", sc: Config{Keys: mustParseKeys("1,1f", "2,2n")}},
+	}
+
+	for _, testingCase := range testTable {
+		expected := randomRecords(50000, &testingCase.sc)
+		sortRecords(expected, &testingCase.sc)
+
+		for _, parallel := range []int{2, 3, 7, 8, 64} {
+			t.Run(fmt.Sprintf("%s parallel %d", testingCase.name, parallel), func(t *testing.T) {
+				conf := testingCase.sc
+				conf.Parallel = parallel
+				result := randomRecords(50000, &conf)
+				sortRecords(result, &conf)
+				for i := range expected {
+					if result[i].line != expected[i].line || result[i].seq != expected[i].seq {
+						t.Fatalf("record %d: expected %q (%d); got %q (%d)",
+							i, expected[i].line, expected[i].seq, result[i].line, result[i].seq)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkSortRecords1M(b *testing.B) {
+	conf := Config{Keys: mustParseKeys("2,2n", "1,1")}
+	source := randomRecords(1000000, &conf)
+	records := make([]record, len(source))
+
+	for _, parallel := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallel=%d", parallel), func(b *testing.B) {
+			conf.Parallel = parallel
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				copy(records, source)
+				b.StartTimer()
+				sortRecords(records, &conf)
+			}
+		})
+	}
+}
+
+func BenchmarkSortLines1M(b *testing.B) {
+	var input strings.Builder
+	for _, rec := range randomRecords(1000000, &Config{}) {
+		input.WriteString(rec.line)
+		input.WriteByte('\n')
+	}
+
+	for _, parallel := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallel=%d", parallel), func(b *testing.B) {
+			// буфер больше входных данных: сортировка в памяти, без временных файлов
+			conf := Config{Parallel: parallel, BufferSize: 1 << 30}
+			b.SetBytes(int64(input.Len()))
+			for i := 0; i < b.N; i++ {
+				var out strings.Builder
+				if err := Sort(strings.NewReader(input.String()), &out, conf); err != nil {
+					b.Fatalf(err.Error())
+				}
+			}
+		})
+	}
+}
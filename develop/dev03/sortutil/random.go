@@ -0,0 +1,63 @@
+package sortutil
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// randomSaltSize - сколько байт соли читается из --random-source или crypto/rand
+const randomSaltSize = 16
+
+// randomKey - значение ключа для -R: MD5 от соли и ключа, затем сам ключ. Равные ключи дают равные
+// значения и остаются рядом, порядок групп задает хэш; при совпадении хэшей группы упорядочены по ключу
+func (s *Config) randomKey(value string, opts keyOptions) string {
+	if opts.fold {
+		value = strings.ToUpper(value)
+	}
+	hash := md5.New()
+	hash.Write(s.RandomSalt)
+	hash.Write([]byte(value))
+	return string(hash.Sum(nil)) + value
+}
+
+// RandomSalt - соль для -R из --seed, --random-source или, если они не заданы, из crypto/rand:
+// при каждом запуске группы перемешиваются по-новому
+func RandomSalt(seed, randomSource string) ([]byte, error) {
+	switch {
+	case seed != "" && randomSource != "":
+		return nil, fmt.Errorf("options '--seed' and '--random-source' are incompatible")
+	case seed != "":
+		return []byte(seed), nil
+	case randomSource != "":
+		return randomSourceSalt(randomSource)
+	default:
+		return readRandomSalt(rand.Reader)
+	}
+}
+
+// randomSourceSalt - соль из файла --random-source: с одним и тем же файлом порядок повторяется
+func randomSourceSalt(name string) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("can not read random source '%s': %s", name, err.Error())
+	}
+	defer file.Close()
+
+	salt, err := readRandomSalt(file)
+	if err != nil {
+		return nil, fmt.Errorf("can not read random source '%s': %s", name, err.Error())
+	}
+	return salt, nil
+}
+
+func readRandomSalt(r io.Reader) ([]byte, error) {
+	salt := make([]byte, randomSaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
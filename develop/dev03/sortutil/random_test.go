@@ -0,0 +1,96 @@
+package sortutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// shuffle - строки после -R с солью seed
+func shuffle(t *testing.T, input string, sc Config, seed string) []string {
+	salt, err := RandomSalt(seed, "")
+	if err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+	sc.RandomSalt = salt
+	var out strings.Builder
+	if err := Sort(strings.NewReader(input), &out, sc); err != nil {
+		t.Fatalf("expected err == nil; got '%s'", err.Error())
+	}
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+func TestRandomSort(t *testing.T) {
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf("group%d %d", i%10, i))
+	}
+	input := strings.Join(lines, "\n")
+
+	testTable := []struct {
+		name string
+		sc   Config
+		// group - ключ группы, строки которой должны идти подряд
+		group func(line string) string
+	}{
+		{name: "whole line -R", sc: Config{RandomSort: true}, group: func(line string) string { return line }},
+		{name: "by key -k1,1R", sc: Config{Keys: mustParseKeys("1,1R")}, group: func(line string) string { return strings.Fields(line)[0] }},
+		{name: "by folded key -k1,1fR", sc: Config{Keys: mustParseKeys("1,1fR")}, group: func(line string) string { return strings.Fields(line)[0] }},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			first := shuffle(t, input, testingCase.sc, "42")
+			if again := shuffle(t, input, testingCase.sc, "42"); strings.Join(again, "\n") != strings.Join(first, "\n") {
+				t.Errorf("expected the same order for the same seed")
+			}
+			if other := shuffle(t, input, testingCase.sc, "43"); strings.Join(other, "\n") == strings.Join(first, "\n") {
+				t.Errorf("expected another order for another seed")
+			}
+
+			sorted := append([]string(nil), first...)
+			sort.Strings(sorted)
+			expected := append([]string(nil), lines...)
+			sort.Strings(expected)
+			if strings.Join(sorted, "\n") != strings.Join(expected, "\n") {
+				t.Fatalf("expected a permutation of input; got %q", first)
+			}
+
+			// группа не должна встречаться снова после того, как закончилась
+			finished := map[string]bool{}
+			for i, line := range first {
+				group := testingCase.group(line)
+				if finished[group] {
+					t.Fatalf("expected lines of group '%s' together; got %q", group, first)
+				}
+				if i+1 == len(first) || testingCase.group(first[i+1]) != group {
+					finished[group] = true
+				}
+			}
+		})
+	}
+}
+
+func TestRandomSalt(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "random")
+	os.WriteFile(source, []byte(strings.Repeat("0123456789", 3)), 0o644)
+	short := filepath.Join(dir, "short")
+	os.WriteFile(short, []byte("0123"), 0o644)
+
+	if salt, err := RandomSalt("", source); err != nil || string(salt) != "0123456789012345" {
+		t.Errorf("expected salt from the first 16 bytes; got %q, %v", salt, err)
+	}
+	if _, err := RandomSalt("", short); err == nil {
+		t.Errorf("expected error for short random source, but err is nil")
+	}
+	if _, err := RandomSalt("1", source); err == nil {
+		t.Errorf("expected error for --seed with --random-source, but err is nil")
+	}
+	if salt, err := RandomSalt("", ""); err != nil || len(salt) != randomSaltSize {
+		t.Errorf("expected random salt; got %q, %v", salt, err)
+	}
+}
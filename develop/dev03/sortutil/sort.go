@@ -0,0 +1,321 @@
+/*
+Package sortutil - сортировка строк в духе GNU sort: ключи -k, числовые, месячные, версионные
+и случайные порядки, сравнение по правилам языка, -u, внешняя сортировка файлов больше памяти
+и слияние уже отсортированных входов. Утилита dev03 - обертка над пакетом: разбирает флаги
+в Config и вызывает Sort, SortInputs, Merge или Check.
+
+	conf := sortutil.Config{Keys: keys, NumericSort: true}
+	if err := conf.Validate(); err != nil { ... }
+	err := sortutil.Sort(os.Stdin, os.Stdout, conf)
+
+Для сравнения строк вне сортировки - Config.Comparator.
+*/
+package sortutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/collate"
+)
+
+// Config - параметры сортировки; нулевое значение - побайтная сортировка строк по возрастанию
+type Config struct {
+	// Keys - ключи -k, см. ParseKeySpec; пусто - сравнивается вся строка
+	Keys []KeySpec
+	// SkipBlanks - -b: пропускать пробелы в начале ключа
+	SkipBlanks bool
+	// Separator - разделитель полей -t; 0 - переход от пробельных символов к непробельным
+	Separator rune
+	// CSV - поля в формате CSV: в кавычках могут быть разделители и переводы строк
+	CSV bool
+	// Collator - сравнение текста по правилам языка, см. NewCollator; nil - побайтно
+	Collator *collate.Collator
+	// FoldCase - -f: не различать регистр
+	FoldCase bool
+	// DictionaryOrder - -d: сравнивать только буквы, цифры и пробелы
+	DictionaryOrder bool
+
+	// порядки -n, -M, -h, -g, -V, -R; одновременно допустим только один, см. Validate
+	NumericSort        bool
+	MonthSort          bool
+	HumanNumericSort   bool
+	GeneralNumericSort bool
+	VersionSort        bool
+	RandomSort         bool
+
+	// Reverse - -r: обратный порядок
+	Reverse bool
+	// Unique - -u: из строк, равных по ключам, выводится первая
+	Unique bool
+	// Stable - -s: строки, равные по ключам, остаются в порядке ввода; без него, как в GNU sort,
+	// они сравниваются побайтно по всей строке (с Reverse - в обратном порядке). С Unique и Count
+	// последнее сравнение не выполняется
+	Stable bool
+	// Count - --count: перед строкой число строк, равных ей по ключам; группирует, как Unique
+	Count bool
+	// Months - таблица месяцев, см. ParseMonths; пусто - английские и русские названия
+	Months [12]string
+	// RandomSalt - соль хэша ключей для -R, см. RandomSalt
+	RandomSalt []byte
+
+	// BufferSize - объем памяти под строки одной порции в байтах; 0 - defaultBufferSize
+	BufferSize int64
+	// TempDir - каталог для временных файлов; пусто - os.TempDir()
+	TempDir string
+	// Parallel - число горутин сортировки порции, см. DefaultParallel; 0 и 1 - без параллельности
+	Parallel int
+}
+
+// Input - именованный источник строк; имя выводится в ошибках и сообщениях Check
+type Input struct {
+	Name string
+	R    io.Reader
+}
+
+// maxInputLines - шаг номеров записей между входами Merge: при равных ключах строки
+// более раннего входа идут первыми
+const maxInputLines = 1 << 40
+
+// Sort - сортировка строк r с выводом в w, каждая строка заканчивается '\n'
+func Sort(r io.Reader, w io.Writer, conf Config) error {
+	return SortInputs([]Input{{Name: "-", R: r}}, w, conf)
+}
+
+// SortInputs - сортировка строк всех входов вместе; последняя строка входа без '\n' не склеивается
+// с первой строкой следующего
+func SortInputs(in []Input, w io.Writer, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(w)
+	if err := externalSort(in, out, &conf); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// Merge - слияние уже отсортированных входов без сортировки (-m)
+func Merge(in []Input, w io.Writer, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	sources := make([]recordSource, len(in))
+	for i, input := range in {
+		sources[i] = &inputSource{lines: conf.readLines(input.R), name: input.Name, seq: int64(i) * maxInputLines, conf: &conf}
+	}
+
+	out := bufio.NewWriter(w)
+	output := &uniqueWriter{w: out, conf: &conf}
+	if err := merge(sources, &conf, output.write); err != nil {
+		return err
+	}
+	if err := output.flush(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// DisorderError - первая строка не по порядку, найденная Check
+type DisorderError struct {
+	Name string
+	Line int64
+	Text string
+}
+
+func (e *DisorderError) Error() string {
+	return fmt.Sprintf("%s:%d: disorder: %s", e.Name, e.Line, e.Text)
+}
+
+// Check - проверка за один проход, что строки уже упорядочены по ключам и флагам сортировки;
+// с Unique равные по ключам строки тоже считаются нарушением порядка. Нарушение - *DisorderError
+func Check(in Input, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	lines := conf.readLines(in.R)
+	var prev record
+	for first := true; ; first = false {
+		// с CSV запись может занимать несколько строк; номер - первая строка записи
+		lineNumber := lines.count + 1
+		line, ok, err := lines.next()
+		if err != nil {
+			return readError(in.Name, err)
+		}
+		if !ok {
+			return nil
+		}
+
+		rec := newRecord(line, lineNumber, &conf)
+		if !first {
+			cmp := compareLines(&prev, &rec, &conf)
+			if cmp > 0 || cmp == 0 && conf.Unique {
+				return &DisorderError{Name: in.Name, Line: lineNumber, Text: line}
+			}
+		}
+		prev = rec
+	}
+}
+
+// Validate - проверка совместимости флагов: "options '-nM' are incompatible", и ключей: поля
+// нумеруются с 1, поэтому ключ с нулевым полем (например, KeySpec{}) - ошибка
+func (s *Config) Validate() error {
+	if orders := s.orderFlags(); len(orders) > 1 {
+		return fmt.Errorf("options '-%s' are incompatible", orders)
+	}
+	for i := range s.Keys {
+		if s.Keys[i].startField < 1 || s.Keys[i].startChar < 1 {
+			return fmt.Errorf("key %d: field and character numbers start at 1, use ParseKeySpec", i+1)
+		}
+	}
+	return nil
+}
+
+func readError(name string, err error) error {
+	return fmt.Errorf("can not read file '%s': %s", name, err.Error())
+}
+
+// inputSource - записи уже отсортированного входа для Merge
+type inputSource struct {
+	lines *lineReader
+	name  string
+	seq   int64
+	conf  *Config
+}
+
+func (src *inputSource) next() (record, bool, error) {
+	line, ok, err := src.lines.next()
+	if err != nil {
+		return record{}, false, readError(src.name, err)
+	}
+	if !ok {
+		return record{}, false, nil
+	}
+	rec := newRecord(line, src.seq, src.conf)
+	src.seq++
+	return rec, true, nil
+}
+
+// orderFlags - заданные глобальные флаги порядка; допустим только один
+func (s *Config) orderFlags() string {
+	var orders []byte
+	flags := []bool{
+		s.DictionaryOrder, s.GeneralNumericSort, s.HumanNumericSort, s.MonthSort, s.NumericSort, s.RandomSort, s.VersionSort,
+	}
+	for i, set := range flags {
+		if set {
+			orders = append(orders, "dghMnRV"[i])
+		}
+	}
+	return string(orders)
+}
+
+// globalOptions - параметры сравнения из глобальных флагов
+func (s *Config) globalOptions() keyOptions {
+	opts := keyOptions{
+		fold: s.FoldCase, dictionary: s.DictionaryOrder, reverse: s.Reverse,
+		blanksStart: s.SkipBlanks, blanksEnd: s.SkipBlanks,
+	}
+	switch {
+	case s.NumericSort:
+		opts.order = orderNumeric
+	case s.MonthSort:
+		opts.order = orderMonth
+	case s.HumanNumericSort:
+		opts.order = orderHuman
+	case s.GeneralNumericSort:
+		opts.order = orderGeneral
+	case s.VersionSort:
+		opts.order = orderVersion
+	case s.RandomSort:
+		opts.order = orderRandom
+	}
+	return opts
+}
+
+// newRecord - запись с выделенными значениями ключей
+func newRecord(line string, seq int64, s *Config) record {
+	rec := record{line: line, seq: seq}
+	global := s.globalOptions()
+	if len(s.Keys) == 0 {
+		// без -k ключ - вся строка; отдельно хранится, только если сравнивается не сама строка
+		if s.precomputed(global) {
+			rec.keys = []string{s.keyValue(wholeLine(line, global), global)}
+		}
+		return rec
+	}
+
+	layout := s.splitter().layout(line)
+	rec.keys = make([]string, len(s.Keys))
+	for i := range s.Keys {
+		opts := s.Keys[i].options(global)
+		rec.keys[i] = s.keyValue(s.Keys[i].extract(layout, opts), opts)
+	}
+	return rec
+}
+
+// splitter - разбиение строки на поля по -t и --csv
+func (s *Config) splitter() fieldSplitter {
+	if s.CSV && s.Separator == 0 {
+		return fieldSplitter{sep: ',', csv: true}
+	}
+	return fieldSplitter{sep: s.Separator, csv: s.CSV}
+}
+
+// compareRecords - сравнение записей по ключам и флагам: < 0, если a идет раньше b, 0 - записи равны по всем ключам
+func compareRecords(a, b *record, s *Config) int {
+	global := s.globalOptions()
+	if len(s.Keys) == 0 {
+		if a.keys != nil {
+			return s.compareValues(a.keys[0], b.keys[0], global)
+		}
+		return s.compareValues(wholeLine(a.line, global), wholeLine(b.line, global), global)
+	}
+
+	for i := range s.Keys {
+		if cmp := s.compareValues(a.keys[i], b.keys[i], s.Keys[i].options(global)); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// wholeLine - ключ без -k: вся строка, с -b без пробелов в начале
+func wholeLine(line string, opts keyOptions) string {
+	if opts.blanksStart {
+		return strings.TrimLeft(line, " \t")
+	}
+	return line
+}
+
+// compareLines - порядок вывода: compareRecords, а при равенстве всех ключей - последнее сравнение
+// всей строки побайтно, как в GNU sort; Stable, Unique и Count его отключают
+func compareLines(a, b *record, s *Config) int {
+	if cmp := compareRecords(a, b, s); cmp != 0 || !s.lastResort() {
+		return cmp
+	}
+	return lastResortCompare(a.line, b.line, s)
+}
+
+// lastResort - выполняется ли последнее сравнение всей строки
+func (s *Config) lastResort() bool {
+	return !s.Stable && !s.Unique && !s.Count
+}
+
+// lastResortCompare - последнее сравнение: побайтно, с Reverse - в обратном порядке
+func lastResortCompare(a, b string, s *Config) int {
+	if s.Reverse {
+		return strings.Compare(b, a)
+	}
+	return strings.Compare(a, b)
+}
+
+// lessRows - порядок записей: по compareLines, равные - в порядке чтения
+func lessRows(a, b record, s *Config) bool {
+	if cmp := compareLines(&a, &b, s); cmp != 0 {
+		return cmp < 0
+	}
+	return a.seq < b.seq
+}
@@ -0,0 +1,93 @@
+package sortutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSorted(t *testing.T) {
+	testTable := []struct {
+		name     string
+		sc       Config
+		in       string
+		disorder string
+	}{
+		{name: "sorted", in: "a\nb\nb\nc\n"},
+		{name: "disorder", in: "a\nc\nb\nd\n", disorder: "-:3: disorder: b"},
+		{name: "numeric -n", sc: Config{NumericSort: true}, in: "-1\n2\n10\n"},
+		{name: "numeric as text", in: "-1\n2\n10\n", disorder: "-:3: disorder: 10"},
+		{name: "reverse -r", sc: Config{Reverse: true}, in: "c\nb\na\n"},
+		{name: "unique -u", sc: Config{Unique: true}, in: "a\nb\nb\n", disorder: "-:3: disorder: b"},
+		{name: "by key", sc: Config{Keys: mustParseKeys("2,2n")}, in: "z 1\nx 2\ny 2\nw 3\n"},
+		{name: "by key compares equal keys as whole lines", sc: Config{Keys: mustParseKeys("2,2n")}, in: "z 1\ny 2\nx 2\nw 3\n", disorder: "-:3: disorder: x 2"},
+		{name: "by key stable -s", sc: Config{Keys: mustParseKeys("2,2n"), Stable: true}, in: "z 1\ny 2\nx 2\nw 3\n"},
+		{name: "by key unique", sc: Config{Keys: mustParseKeys("2,2n"), Unique: true}, in: "z 1\ny 2\nx 2\n", disorder: "-:3: disorder: x 2"},
+		{
+			name: "csv record spans lines", sc: Config{CSV: true, Keys: mustParseKeys("2,2")},
+			in: "1,a\n2,\"b\nb\"\n3,a\n", disorder: "-:4: disorder: 3,a",
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			err := Check(Input{Name: "-", R: strings.NewReader(testingCase.in)}, testingCase.sc)
+			if testingCase.disorder == "" {
+				if err != nil {
+					t.Errorf("expected err == nil; got '%s'", err.Error())
+				}
+				return
+			}
+			disorder, ok := err.(*DisorderError)
+			if !ok {
+				t.Fatalf("expected disorder error; got '%v'", err)
+			}
+			if disorder.Error() != testingCase.disorder {
+				t.Errorf("expected '%s'; got '%s'", testingCase.disorder, disorder.Error())
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testTable := []struct {
+		name        string
+		conf        Config
+		errorString string
+	}{
+		{name: "zero value", conf: Config{}},
+		{name: "parsed keys", conf: Config{Keys: mustParseKeys("2,2n", "1.3")}},
+		{name: "incompatible orders", conf: Config{NumericSort: true, MonthSort: true}, errorString: "options '-Mn' are incompatible"},
+		{
+			name: "zero value key", conf: Config{Keys: []KeySpec{{}}},
+			errorString: "key 1: field and character numbers start at 1, use ParseKeySpec",
+		},
+		{
+			name: "zero value key after parsed", conf: Config{Keys: append(mustParseKeys("1,1"), KeySpec{})},
+			errorString: "key 2: field and character numbers start at 1, use ParseKeySpec",
+		},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			err := testingCase.conf.Validate()
+			if testingCase.errorString == "" {
+				if err != nil {
+					t.Errorf("expected err == nil; got '%s'", err.Error())
+				}
+				return
+			}
+			if err == nil || err.Error() != testingCase.errorString {
+				t.Fatalf("expected err.Error() == '%s'; got '%v'", testingCase.errorString, err)
+			}
+
+			// Sort, Merge и Check не доходят до разбора строк
+			var out strings.Builder
+			if err := Sort(strings.NewReader("b\na\n"), &out, testingCase.conf); err == nil || out.Len() != 0 {
+				t.Errorf("expected Sort error without output; got '%v', %q", err, out.String())
+			}
+			if err := Check(Input{Name: "-", R: strings.NewReader("a\nb\n")}, testingCase.conf); err == nil {
+				t.Errorf("expected Check error, but err is nil")
+			}
+		})
+	}
+}
@@ -8,19 +8,25 @@ package main
 
 Поддержать ключи
 
--k — указание колонки для сортировки
+-k — указание колонки для сортировки					(ключи GNU sort, см. sortutil/keys.go)
 -n — сортировать по числовому значению
 -r — сортировать в обратном порядке
--u — не выводить повторяющиеся строки
+-u — не выводить повторяющиеся строки: из строк, равных по ключам, выводится первая
 
 Дополнительное
 
 Поддержать ключи
 
--M — сортировать по названию месяца
--b — игнорировать хвостовые пробелы							(игнорируются по умолчанию)
--c — проверять отсортированы ли данные
--h — сортировать по числовому значению с учётом суффиксов  	(?)
+-M — сортировать по названию месяца (см. sortutil/months.go)
+-b — игнорировать пробелы в начале ключа
+-c — проверять отсортированы ли данные (-C - без сообщения)
+-s — стабильная сортировка: строки, равные по ключам, не сравниваются целиком, а остаются в порядке ввода
+-h — сортировать по числовому значению с учётом суффиксов
+-g — сортировать по числу с плавающей точкой
+-V — сортировать по номеру версии
+-R — перемешать строки, равные ключи остаются рядом
+-f — не различать регистр
+-d — словарный порядок: только буквы, цифры и пробелы
 
 Программа должна проходить все тесты. Код должен проходить проверки go vet и golint.
 
@@ -34,265 +40,286 @@ OK go vet -c=10 task.go
 OK golint task.go
 OK go test -run ''  (coverage: 77.2%)
 
+Сортировка вынесена в пакет sortutil, утилита только разбирает флаги и открывает файлы.
+Файлы больше памяти сортируются внешней сортировкой (sortutil/external.go): строки читаются порциями
+не больше -S байт, каждая порция сортируется и сбрасывается во временный файл в каталоге -T,
+затем файлы сливаются k-путевым слиянием через кучу.
+
 Запуск:
-go run task.go -M -r -u sort1.txt
+go run . -M -r -u sort1.txt
+go run . -k2,2n -k1,1r -k3.4,3.8 data.txt
+go run . -t : -k3,3n /etc/passwd
+go run . --csv -k2,2 export.csv
+go run . -S 256M -T /var/tmp -n huge.log
+go run . -collate ru -f words.txt
+cat b.txt | go run . -o all.txt a.txt - c.txt
+go run . -m -o sorted.txt sorted.txt part2.txt
+
+Флаги (значения коротких флагов можно писать слитно, как в GNU sort: -k2,2n, -t:, -S64M):
+-k	ключ сортировки POS1[,POS2][OPTS], можно повторять
+-t	разделитель полей - один символ
+-csv	поля в формате CSV (разделитель ',' или -t): "поле, с запятой", "поле ""с кавычками"""
+-S	размер буфера: число с суффиксом b, K, M, G, T; без суффикса - KiB, как в GNU sort (64M)
+-T	каталог для временных файлов (os.TempDir())
+-o	выходной файл; заменяется после успешной сортировки, поэтому может быть и входным
+-m	слияние уже отсортированных файлов без сортировки; равные строки - в порядке файлов
+-months	названия месяцев для -M: локаль (en, ru) или 12 месяцев через запятую; по умолчанию английские и русские
+-seed	соль для -R: с одним значением порядок повторяется от запуска к запуску
+-random-source	соль для -R из первых 16 байт файла
+-count	как -u, но перед строкой выводится число равных ей строк, как в uniq -c
+-parallel	число горутин сортировки порции (по числу процессоров, не больше 8), см. sortutil/parallel.go
+-collate	сравнение текста по правилам языка (ru, en, und - Unicode Collation Algorithm):
+	"ёж" между "еж" и "жук", "Яблоко" рядом с "яблоко"; без флага строки сравниваются побайтно
 */
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"regexp"
-	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"wbschool_exam_L2/develop/dev03/sortutil"
 )
 
-// SortConfig - Конфигурация сортировки
+// SortConfig - Конфигурация сортировки: параметры sortutil и флаги самой утилиты
 type SortConfig struct {
-	sortColumn          int
-	sortByNumericValue  bool
-	reverseSort         bool
-	uniqueRows          bool
-	sortByMonth         bool
+	sortutil.Config
 	isRowsAlreadySorted bool
-	months              [12]string
-	filename            string
+	// checkQuiet - -C: проверка без сообщения о первой строке не по порядку
+	checkQuiet bool
+	// files - входные файлы; пусто или "-" - стандартный ввод
+	files []string
+	// output - выходной файл -o; пусто - stdout
+	output string
+	// mergeOnly - входные файлы уже отсортированы, только слияние (-m)
+	mergeOnly bool
 }
 
 // NewSortConfig - Конструктор конфига
 func NewSortConfig() *SortConfig {
-	s := SortConfig{}
-	s.months = [12]string{"янв", "фев", "мар", "апр", "май", "июн", "июл", "авг", "сен", "окт", "ноя", "дек"}
-	flag.IntVar(&s.sortColumn, "k", 0, "Sets column for sort")
-	flagN := flag.Bool("n", false, "Makes sort by numeric value")
-	flagR := flag.Bool("r", false, "Makes reverse sort")
-	flagU := flag.Bool("u", false, "Ignore duplicate lines")
-	flagM := flag.Bool("M", false, "Makes sort by month")
-	flagC := flag.Bool("c", false, "Check if rows already sorted")
-
-	flag.Parse()
-
-	args := flag.Args()
-	s.sortByNumericValue = *flagN
-	s.reverseSort = *flagR
-	s.uniqueRows = *flagU
-	s.sortByMonth = *flagM
-	s.isRowsAlreadySorted = *flagC
-
-	if len(args) == 1 {
-		s.filename = args[0]
-	} else {
-		log.Fatalf("The argument (path to the file name) must be one")
+	s, err := parseArgs(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf(err.Error())
 	}
-
-	return &s
+	return s
 }
 
-// Start - Точка входа в программу сортировки
-func Start(s *SortConfig) (string, error) {
-	rows, err := readFile(s.filename)
-	if err != nil {
-		return "", fmt.Errorf("can not read file '%s': %s", s.filename, err.Error())
+// parseArgs - конфиг из аргументов командной строки; флаги регистрируются в flags
+func parseArgs(flags *flag.FlagSet, args []string) (*SortConfig, error) {
+	s := SortConfig{}
+	flags.Func("k", "Sets sort key POS1[,POS2][OPTS], can be repeated", func(value string) error {
+		key, err := sortutil.ParseKeySpec(value)
+		s.Keys = append(s.Keys, key)
+		return err
+	})
+	flags.BoolVar(&s.SkipBlanks, "b", false, "Ignore leading blanks")
+	flags.Func("t", "Use SEP instead of non-blank to blank transition as field separator", func(value string) error {
+		sep, err := parseSeparator(value)
+		s.Separator = sep
+		return err
+	})
+	flags.BoolVar(&s.CSV, "csv", false, "Parse fields as CSV: quoted fields may contain separators and newlines (',' by default)")
+	flags.BoolVar(&s.FoldCase, "f", false, "Fold lower case to upper case characters")
+	flags.BoolVar(&s.DictionaryOrder, "d", false, "Consider only blanks and alphanumeric characters")
+	flags.Func("collate", "Compare text by Unicode collation rules of the language: ru, en, und", func(value string) error {
+		collator, err := sortutil.NewCollator(value)
+		s.Collator = collator
+		return err
+	})
+	flagN := flags.Bool("n", false, "Makes sort by numeric value")
+	flagR := flags.Bool("r", false, "Makes reverse sort")
+	flagU := flags.Bool("u", false, "Output only the first of lines with equal keys")
+	flags.BoolVar(&s.Count, "count", false, "Like -u, but prefix lines by number of lines with equal keys")
+	flagM := flags.Bool("M", false, "Makes sort by month")
+	flags.Func("months", "Sets month names for -M: locale (en, ru) or 12 comma-separated months, variants separated by '|'", func(value string) error {
+		months, err := sortutil.ParseMonths(value)
+		s.Months = months
+		return err
+	})
+	flagC := flags.Bool("c", false, "Check if rows already sorted, report first disorder")
+	flags.BoolVar(&s.checkQuiet, "C", false, "Like -c, but do not report first disorder")
+	flags.BoolVar(&s.Stable, "s", false, "Stabilize sort by disabling last-resort comparison of whole lines")
+	flags.BoolVar(&s.HumanNumericSort, "h", false, "Makes sort by human readable numbers (2K, 1.5M, 1GiB, 10MB)")
+	flags.BoolVar(&s.GeneralNumericSort, "g", false, "Makes sort by general numeric value (1e3, inf, nan)")
+	flags.BoolVar(&s.VersionSort, "V", false, "Makes natural sort of version numbers")
+	flags.BoolVar(&s.RandomSort, "R", false, "Shuffle, but group identical keys together")
+	seed := flags.String("seed", "", "Sets seed for -R: the same seed gives the same order")
+	randomSource := flags.String("random-source", "", "Gets salt for -R from the first bytes of file")
+	flags.Func("S", "Sets main memory buffer size (suffixes b, K, M, G, T; KiB by default)", func(value string) error {
+		size, err := parseBufferSize(value)
+		s.BufferSize = size
+		return err
+	})
+	flags.StringVar(&s.TempDir, "T", "", "Sets directory for temporary files")
+	flags.StringVar(&s.output, "o", "", "Write result to file instead of standard output")
+	flags.BoolVar(&s.mergeOnly, "m", false, "Merge already sorted files; do not sort")
+	s.Parallel = sortutil.DefaultParallel()
+	flags.Func("parallel", fmt.Sprintf("Sets number of sorts run concurrently (%d)", s.Parallel), func(value string) error {
+		n, err := parseParallel(value)
+		s.Parallel = n
+		return err
+	})
+
+	if err := flags.Parse(splitAttachedValues(flags, args)); err != nil {
+		return nil, err
 	}
 
-	return sortRows(rows, s)
-}
+	s.NumericSort = *flagN
+	s.Reverse = *flagR
+	s.Unique = *flagU
+	s.MonthSort = *flagM
+	s.isRowsAlreadySorted = *flagC || s.checkQuiet
 
-func readFile(filename string) ([]string, error) {
-	rows := []string{}
-	file, err := os.Open(filename)
-	if err != nil {
-		return rows, err
+	if err := s.Validate(); err != nil {
+		return nil, err
 	}
-	defer file.Close()
 
-	sc := bufio.NewScanner(file)
-	for sc.Scan() {
-		rows = append(rows, sc.Text())
+	salt, err := sortutil.RandomSalt(*seed, *randomSource)
+	if err != nil {
+		return nil, err
 	}
-	return rows, nil
-}
+	s.RandomSalt = salt
 
-func uniqualizer(rows []string) []string {
-	tempBuf := make(map[string]bool)
-	for _, row := range rows {
-		tempBuf[row] = true
+	if s.isRowsAlreadySorted && s.output != "" {
+		return nil, fmt.Errorf("options '-co' are incompatible")
 	}
 
-	// "очищаем" исходный слайс и заполняем его снова
-	rows = rows[:0]
-	for key := range tempBuf {
-		rows = append(rows, key)
+	s.files = flags.Args()
+	if s.isRowsAlreadySorted && len(s.files) > 1 {
+		return nil, fmt.Errorf("extra operand '%s' not allowed with -c", s.files[1])
 	}
-	return rows
-}
 
-func getColumnValue(row string, s *SortConfig) (string, error) {
-	// поиск разделителя: один или более пробельных символов
-	re := regexp.MustCompile(`\s+`)
-	// -b тег по умолчанию - при работе с колонками отрезаем пробелы вначале и в конце.
-	listOfColumns := re.Split(strings.TrimSpace(row), -1)
-	if len(listOfColumns) >= s.sortColumn {
-		return listOfColumns[s.sortColumn-1], nil
-	}
-	return "", fmt.Errorf("can not find column")
+	return &s, nil
 }
 
-func sortRows(rows []string, s *SortConfig) (string, error) {
-	var sourceRows []string
-	if s.isRowsAlreadySorted {
-		sourceRows = make([]string, len(rows))
-		_ = copy(sourceRows, rows)
-
-		// принудительно игнорируем все остальные флаги, если есть флаг -c
-		s.sortColumn = 0
-		s.sortByMonth = false
-		s.sortByNumericValue = false
-		s.reverseSort = false
-		s.uniqueRows = false
-	}
+// splitAttachedValues - значения коротких флагов, записанные слитно, как в GNU sort: "-k2,2n" => "-k", "2,2n",
+// "-t:" => "-t", ":"; пакет flag понимает только "-k 2,2n" и "-k=2,2n". Разбор заканчивается на первом
+// аргументе, который не является флагом, или на "--", как и в flag.Parse
+func splitAttachedValues(flags *flag.FlagSet, args []string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			return append(result, args[i:]...)
+		}
 
-	switch {
-	case s.sortColumn > 0:
-		{
-			// уникализируем, если надо. Уникализация по колонке - не работает, т.к. по заданию - уникализация строки.
-			if s.uniqueRows {
-				rows = uniqualizer(rows)
+		name := strings.TrimPrefix(arg[1:], "-")
+		switch {
+		case strings.Contains(name, "="):
+			result = append(result, arg)
+		case flags.Lookup(name) != nil:
+			// флаг целиком; значение флага, отличного от булева, - следующий аргумент, даже если начинается с '-'
+			result = append(result, arg)
+			if !isBoolFlag(flags.Lookup(name)) && i+1 < len(args) {
+				i++
+				result = append(result, args[i])
 			}
-
-			sort.SliceStable(rows, func(i, j int) bool {
-				// выбираем значение в выбранной колонке
-				ith, err := getColumnValue(rows[i], s)
-				if err != nil {
-					return false
-				}
-				jth, err := getColumnValue(rows[j], s)
-				if err != nil {
-					return false
-				}
-
-				// сортировка по числам
-				if s.sortByNumericValue {
-					if s.reverseSort {
-						return !ithNumLessThanJth(ith, jth)
-					}
-					return ithNumLessThanJth(ith, jth)
-				}
-
-				// сортировка по месяцам
-				if s.sortByMonth {
-					//fmt.Printf("%#v\n\n", s)
-					if s.reverseSort {
-						return !ithMonthLessThanJth(ith, jth, s)
-					}
-					return ithMonthLessThanJth(ith, jth, s)
-				}
-
-				if s.reverseSort {
-					return ith < jth
-				}
-				return ith > jth
-			})
+		case arg[1] != '-' && flags.Lookup(arg[1:2]) != nil && !isBoolFlag(flags.Lookup(arg[1:2])):
+			result = append(result, arg[:2], arg[2:])
+		default:
+			// неизвестный флаг - ошибку выдаст flags.Parse
+			result = append(result, arg)
 		}
-	case s.sortByNumericValue:
-		{
-			// уникализируем, если надо
-			if s.uniqueRows {
-				rows = uniqualizer(rows)
-			}
+	}
+	return result
+}
 
-			sort.SliceStable(rows, func(i, j int) bool {
-				if s.reverseSort {
-					return !ithNumLessThanJth(rows[i], rows[j])
-				}
-				return ithNumLessThanJth(rows[i], rows[j])
-			})
-		}
-	case s.uniqueRows:
-		{
-			rows = uniqualizer(rows)
-			sort.SliceStable(rows, func(i, j int) bool {
-				// сортировка по месяцам
-				if s.sortByMonth {
-					if s.reverseSort {
-						return !ithMonthLessThanJth(rows[i], rows[j], s)
-					}
-					return ithMonthLessThanJth(rows[i], rows[j], s)
-				}
-
-				if s.reverseSort {
-					return rows[i] > rows[j]
-				}
-				return rows[i] < rows[j]
-			})
-		}
-	case s.sortByMonth:
-		{
-			sort.SliceStable(rows, func(i, j int) bool {
-				if s.reverseSort {
-					return !ithMonthLessThanJth(rows[i], rows[j], s)
-				}
-				return ithMonthLessThanJth(rows[i], rows[j], s)
-			})
-		}
-	default:
-		sort.SliceStable(rows, func(i, j int) bool {
-			if s.reverseSort {
-				return rows[i] > rows[j]
-			}
-			return rows[i] < rows[j]
-		})
+func isBoolFlag(f *flag.Flag) bool {
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && boolFlag.IsBoolFlag()
+}
+
+// parseSeparator - разделитель -t: один символ, либо "\\t" или "\\0"
+func parseSeparator(value string) (rune, error) {
+	switch value {
+	case `\t`:
+		return '\t', nil
+	case `\0`:
+		return 0, fmt.Errorf("NUL separator is not supported")
 	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("multi-character tab '%s'", value)
+	}
+	sep, _ := utf8.DecodeRuneInString(value)
+	if sep == '\n' {
+		return 0, fmt.Errorf("newline can not be a field separator")
+	}
+	return sep, nil
+}
 
-	if s.isRowsAlreadySorted {
-		for i, row := range rows {
-			if row != sourceRows[i] {
-				return "false", nil
-			}
+// parseBufferSize - разбор размера буфера: "1024b", "512K", "64M", "1G"; без суффикса - KiB
+func parseBufferSize(value string) (int64, error) {
+	units := map[byte]int64{'b': 1, 'K': 1 << 10, 'k': 1 << 10, 'M': 1 << 20, 'G': 1 << 30, 'T': 1 << 40}
+	multiplier := int64(1 << 10)
+	if value != "" {
+		if unit, ok := units[value[len(value)-1]]; ok {
+			multiplier = unit
+			value = value[:len(value)-1]
 		}
-		return "true", nil
 	}
 
-	var result strings.Builder
-	lenRows := len(rows)
-	for i, row := range rows {
-		if i < lenRows-1 {
-			_, _ = result.WriteString(row + "\n")
-		} else {
-			_, _ = result.WriteString(row)
-		}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid buffer size '%s'", value)
+	}
+	if size > (1<<63-1)/multiplier {
+		return 0, fmt.Errorf("buffer size '%s' is too large", value)
 	}
+	return size * multiplier, nil
+}
 
-	return result.String(), nil
+// parseParallel - значение --parallel: положительное число
+func parseParallel(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid number of parallel sorts '%s'", value)
+	}
+	return n, nil
 }
 
-func ithNumLessThanJth(strI, strJ string) bool {
-	ith, _ := strconv.Atoi(strI)
-	jth, _ := strconv.Atoi(strJ)
-	return ith < jth
+// Start - Точка входа в программу сортировки
+func Start(s *SortConfig) (string, error) {
+	var result strings.Builder
+	if err := sortFiles(s, os.Stdin, &result); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(result.String(), "\n"), nil
 }
 
-func ithMonthLessThanJth(strI, strJ string, s *SortConfig) bool {
-	for _, month := range s.months {
-		switch {
-		case month == strI:
-			return true
-		case month == strJ:
-			return false
-		default:
-			continue
-		}
+// sortFiles - сортировка строк всех файлов s.files вместе (с -m - слияние, с -c - проверка) с выводом в w,
+// каждая строка заканчивается '\n'; без файлов и вместо "-" читается stdin
+func sortFiles(s *SortConfig, stdin io.Reader, w io.Writer) error {
+	in, err := openInputs(s.files, stdin)
+	if err != nil {
+		return err
+	}
+	defer in.close()
+
+	switch {
+	case s.isRowsAlreadySorted:
+		return sortutil.Check(in[0], s.Config)
+	case s.mergeOnly:
+		return sortutil.Merge(in, w, s.Config)
+	default:
+		return sortutil.SortInputs(in, w, s.Config)
 	}
-	return true
 }
 
 func main() {
 	s := NewSortConfig()
-	res, err := Start(s)
+	err := sortToOutput(s, os.Stdin, os.Stdout)
+	var disorder *sortutil.DisorderError
+	if errors.As(err, &disorder) {
+		if !s.checkQuiet {
+			fmt.Fprintf(os.Stderr, "sort: %s\n", err.Error())
+		}
+		os.Exit(1)
+	}
 	if err != nil {
 		log.Fatalf(err.Error())
 	}
-	fmt.Println(res)
 }
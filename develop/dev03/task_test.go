@@ -1,9 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"testing"
+
+	"wbschool_exam_L2/develop/dev03/sortutil"
 )
 
 func TestSortUtil(t *testing.T) {
@@ -12,6 +17,16 @@ func TestSortUtil(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 
+	// ключи -k2,2 и -k6,6
+	key2, err := sortutil.ParseKeySpec("2,2")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	key6, err := sortutil.ParseKeySpec("6,6")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
 	months := [12]string{"янв", "фев", "мар", "апр", "май", "июн", "июл", "авг", "сен", "окт", "ноя", "дек"}
 
 	testTable := []struct {
@@ -24,153 +39,185 @@ func TestSortUtil(t *testing.T) {
 		{
 			name: "simple sort without parametres",
 			sc: SortConfig{
-				filename: "testing/sort3.txt",
+				files: []string{"testing/sort3.txt"},
 			},
 			out: "1\n11\n2\n4\n4\n5\n7\n8\n9",
 		},
 		{
 			name: "simple reverse sort",
 			sc: SortConfig{
-				filename:    "testing/sort3.txt",
-				reverseSort: true,
+				Config: sortutil.Config{
+					Reverse: true,
+				},
+				files: []string{"testing/sort3.txt"},
 			},
 			out: "9\n8\n7\n5\n4\n4\n2\n11\n1",
 		},
 		{
-			name: "sort by colon k=2",
+			name: "sort by column -k2,2",
 			sc: SortConfig{
-				filename:   "testing/sort1.txt",
-				sortColumn: 2,
+				Config: sortutil.Config{
+					Keys: []sortutil.KeySpec{key2},
+				},
+				files: []string{"testing/sort1.txt"},
 			},
-			out: "drwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\ndrwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/",
+			out: "drwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/",
 		},
 		{
-			name: "sort by colon (just reverse) k=2 -r",
+			name: "sort by column (just reverse) -k2,2 -r",
 			sc: SortConfig{
-				filename:    "testing/sort1.txt",
-				sortColumn:  2,
-				reverseSort: true,
+				Config: sortutil.Config{
+					Keys:    []sortutil.KeySpec{key2},
+					Reverse: true,
+				},
+				files: []string{"testing/sort1.txt"},
 			},
-			out: "drwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/",
+			out: "drwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\ndrwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/",
 		},
 		{
-			name: "sort by colon (as number) k=2 -n",
+			name: "sort by column (as number) -k2,2 -n",
 			sc: SortConfig{
-				filename:           "testing/sort1.txt",
-				sortByNumericValue: true,
-				sortColumn:         2,
+				Config: sortutil.Config{
+					NumericSort: true,
+					Keys:        []sortutil.KeySpec{key2},
+				},
+				files: []string{"testing/sort1.txt"},
 			},
-			out: "drwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md",
+			out: "drwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md",
 		},
 		{
-			name: "sort by colon (unique) k=2 -u",
+			name: "sort by column (unique, first of equal keys) -k2,2 -u",
 			sc: SortConfig{
-				filename:   "testing/sort1.txt",
-				sortColumn: 2,
-				uniqueRows: true,
+				Config: sortutil.Config{
+					Keys:   []sortutil.KeySpec{key2},
+					Unique: true,
+				},
+				files: []string{"testing/sort1.txt"},
 			},
-			out: "drwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\ndrwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/",
+			out: "drwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/",
 		},
 		{
-			name: "sort by colon (as number, reverse) k=2 -n -r",
+			name: "sort by column (as number, reverse) -k2,2 -n -r",
 			sc: SortConfig{
-				filename:           "testing/sort1.txt",
-				sortByNumericValue: true,
-				reverseSort:        true,
-				sortColumn:         2,
+				Config: sortutil.Config{
+					NumericSort: true,
+					Reverse:     true,
+					Keys:        []sortutil.KeySpec{key2},
+				},
+				files: []string{"testing/sort1.txt"},
 			},
-			out: "-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\ndrwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/",
+			out: "-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\ndrwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/",
 		},
 		{
-			name: "sort by colon (as Month) k=6 -M",
+			name: "sort by column (as Month) -k6,6 -M",
 			sc: SortConfig{
-				months:      months,
-				filename:    "testing/sort1.txt",
-				sortByMonth: true,
-				sortColumn:  6,
+				Config: sortutil.Config{
+					Months:    months,
+					MonthSort: true,
+					Keys:      []sortutil.KeySpec{key6},
+				},
+				files: []string{"testing/sort1.txt"},
 			},
 			out: "drwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod",
 		},
 		{
-			name: "sort by colon (as Month, reverse) k=6 -M -r",
+			name: "sort by column (as Month, reverse) -k6,6 -M -r",
 			sc: SortConfig{
-				months:      months,
-				filename:    "testing/sort1.txt",
-				sortByMonth: true,
-				sortColumn:  6,
-				reverseSort: true,
+				Config: sortutil.Config{
+					Months:    months,
+					MonthSort: true,
+					Keys:      []sortutil.KeySpec{key6},
+					Reverse:   true,
+				},
+				files: []string{"testing/sort1.txt"},
 			},
 			out: "-rw-r--r-- 2 vital 197121  323 дек 18 16:42 go.mod\ndrwxr-xr-x 5 vital 197121    0 ноя 10 17:12 listing/\n-rw-r--r-- 11 vital 197121 1349 июл  8 11:34 README.md\ndrwxr-xr-x 7 vital 197121    0 май  8 11:34 pattern/\n-rw-r--r-- 6 vital 197121 1349 апр  8 11:34 README.md\n-rw-r--r-- 5 vital 197121 3591 мар 11 11:05 main.go\n-rw-r--r-- 4 vital 197121 2311 фев 18 16:44 go.sum\ndrwxr-xr-x 1 vital 197121    0 янв  8 11:34 develop/",
 		},
 		{
 			name: "sort by numeric value -n",
 			sc: SortConfig{
-				filename:           "testing/sort3.txt",
-				sortByNumericValue: true,
+				Config: sortutil.Config{
+					NumericSort: true,
+				},
+				files: []string{"testing/sort3.txt"},
 			},
 			out: "1\n2\n4\n4\n5\n7\n8\n9\n11",
 		},
 		{
 			name: "sort by numeric value (unique) -n -u",
 			sc: SortConfig{
-				filename:           "testing/sort3.txt",
-				sortByNumericValue: true,
-				uniqueRows:         true,
+				Config: sortutil.Config{
+					NumericSort: true,
+					Unique:      true,
+				},
+				files: []string{"testing/sort3.txt"},
 			},
 			out: "1\n2\n4\n5\n7\n8\n9\n11",
 		},
 		{
 			name: "sort by numeric value (reverse) -n -r",
 			sc: SortConfig{
-				filename:           "testing/sort3.txt",
-				sortByNumericValue: true,
-				reverseSort:        true,
+				Config: sortutil.Config{
+					NumericSort: true,
+					Reverse:     true,
+				},
+				files: []string{"testing/sort3.txt"},
 			},
 			out: "11\n9\n8\n7\n5\n4\n4\n2\n1",
 		},
 		{
 			name: "sort with unique rows -u",
 			sc: SortConfig{
-				filename:   "testing/sort2.txt",
-				uniqueRows: true,
+				Config: sortutil.Config{
+					Unique: true,
+				},
+				files: []string{"testing/sort2.txt"},
 			},
 			out: "LAPTOP\nRedHat\ncomputer\ndata\ndebian\nlaptop\nmouse",
 		},
 		{
 			name: "sort with unique rows (reverse) -u -r",
 			sc: SortConfig{
-				filename:    "testing/sort2.txt",
-				uniqueRows:  true,
-				reverseSort: true,
+				Config: sortutil.Config{
+					Unique:  true,
+					Reverse: true,
+				},
+				files: []string{"testing/sort2.txt"},
 			},
 			out: "mouse\nlaptop\ndebian\ndata\ncomputer\nRedHat\nLAPTOP",
 		},
 		{
 			name: "sort by Month -M",
 			sc: SortConfig{
-				months:      months,
-				filename:    "testing/sort4.txt",
-				sortByMonth: true,
+				Config: sortutil.Config{
+					Months:    months,
+					MonthSort: true,
+				},
+				files: []string{"testing/sort4.txt"},
 			},
 			out: "янв\nфев\nфев\nмар\nмар\nапр\nиюн\nиюл\nноя\nдек",
 		},
 		{
 			name: "sort by Month (reverse) -M -r",
 			sc: SortConfig{
-				months:      months,
-				filename:    "testing/sort4.txt",
-				sortByMonth: true,
-				reverseSort: true,
+				Config: sortutil.Config{
+					Months:    months,
+					MonthSort: true,
+					Reverse:   true,
+				},
+				files: []string{"testing/sort4.txt"},
 			},
 			out: "дек\nноя\nиюл\nиюн\nапр\nмар\nмар\nфев\nфев\nянв",
 		},
 		{
 			name: "sort by Month (unique) -M -u",
 			sc: SortConfig{
-				months:      months,
-				filename:    "testing/sort4.txt",
-				sortByMonth: true,
-				uniqueRows:  true,
+				Config: sortutil.Config{
+					Months:    months,
+					MonthSort: true,
+					Unique:    true,
+				},
+				files: []string{"testing/sort4.txt"},
 			},
 			out: "янв\nфев\nмар\nапр\nиюн\nиюл\nноя\nдек",
 		},
@@ -178,22 +225,23 @@ func TestSortUtil(t *testing.T) {
 			name: "check, if already sorted -c",
 			sc: SortConfig{
 				isRowsAlreadySorted: true,
-				filename:            "testing/sort5.txt",
+				files:               []string{"testing/sort5.txt"},
 			},
-			out: "true",
+			out: "",
 		},
 		{
 			name: "check, if already sorted -c",
 			sc: SortConfig{
 				isRowsAlreadySorted: true,
-				filename:            "testing/sort2.txt",
+				files:               []string{"testing/sort2.txt"},
 			},
-			out: "false",
+			haveError:   true,
+			errorString: "testing/sort2.txt:3: disorder: data",
 		},
 		{
 			name: "error: file not found",
 			sc: SortConfig{
-				filename: "testing/sort13.txt",
+				files: []string{"testing/sort13.txt"},
 			},
 			haveError:   true,
 			errorString: "can not read file 'testing/sort13.txt': open testing/sort13.txt: The system cannot find the file specified.",
@@ -337,3 +385,184 @@ func exists(path string) (bool, error) {
 	}
 	return false, err
 }
+
+func TestParseBufferSize(t *testing.T) {
+	testTable := []struct {
+		in        string
+		out       int64
+		haveError bool
+	}{
+		{in: "100b", out: 100},
+		{in: "4", out: 4 << 10},
+		{in: "512K", out: 512 << 10},
+		{in: "64M", out: 64 << 20},
+		{in: "2G", out: 2 << 30},
+		{in: "1T", out: 1 << 40},
+		{in: "", haveError: true},
+		{in: "M", haveError: true},
+		{in: "0", haveError: true},
+		{in: "-5K", haveError: true},
+		{in: "10X", haveError: true},
+		{in: "99999999999T", haveError: true},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.in, func(t *testing.T) {
+			result, err := parseBufferSize(testingCase.in)
+			if testingCase.haveError {
+				if err == nil {
+					t.Errorf("expected error, but err is nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if result != testingCase.out {
+				t.Errorf("expected %d; got %d", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestParseSeparator(t *testing.T) {
+	testTable := []struct {
+		in        string
+		out       rune
+		haveError bool
+	}{
+		{in: ":", out: ':'},
+		{in: "\t", out: '\t'},
+		{in: `\t`, out: '\t'},
+		{in: "│", out: '│'},
+		{in: "", haveError: true},
+		{in: "::", haveError: true},
+		{in: `\0`, haveError: true},
+		{in: "\n", haveError: true},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.in, func(t *testing.T) {
+			result, err := parseSeparator(testingCase.in)
+			if testingCase.haveError != (err != nil) {
+				t.Fatalf("expected error: %v; got %v", testingCase.haveError, err)
+			}
+			if result != testingCase.out {
+				t.Errorf("expected %q; got %q", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestParseParallel(t *testing.T) {
+	if n, err := parseParallel("4"); err != nil || n != 4 {
+		t.Errorf("expected 4; got %d, %v", n, err)
+	}
+	for _, value := range []string{"0", "-1", "many", ""} {
+		if _, err := parseParallel(value); err == nil {
+			t.Errorf("expected error for '%s', but err is nil", value)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	files := writeFiles(t, "b 2 x\na 10 y\nc 2 z\na 2 w\n",
+		"root:x:0:0:root:/root:/bin/bash\nwww:x:33:33:www:/var/www:/bin/sh\nbin:x:2:2:bin:/bin:/bin/false\nnobody:x:65534:65534::/:/bin/false\n",
+		"id,name\n3,\"Smith, John\"\n1,\"Doe, Jane\"\n2,Adams\n")
+
+	testTable := []struct {
+		name      string
+		args      []string
+		stdin     string
+		out       string
+		haveError bool
+	}{
+		{
+			name: "attached key specs -k2,2n -k1,1r",
+			args: []string{"-k2,2n", "-k1,1r", files[0]},
+			out:  "c 2 z\nb 2 x\na 2 w\na 10 y\n",
+		},
+		{
+			name: "separate and = key specs",
+			args: []string{"-k", "2,2n", "-k=1,1r", files[0]},
+			out:  "c 2 z\nb 2 x\na 2 w\na 10 y\n",
+		},
+		{
+			name: "character positions -b -k3.1,3.1",
+			args: []string{"-b", "-k3.1,3.1", files[0]},
+			out:  "a 2 w\nb 2 x\na 10 y\nc 2 z\n",
+		},
+		{
+			name:  "attached value after boolean flag",
+			args:  []string{"-r", "-k1,1", "-"},
+			stdin: "a\nc\nb\n",
+			out:   "c\nb\na\n",
+		},
+		{
+			// "-k1,1" - имя выходного файла, поэтому -c несовместим с -o
+			name:      "value of -o is not split",
+			args:      []string{"-o", "-k1,1", "-c"},
+			haveError: true,
+		},
+		{
+			name: "attached separator -t: -k3,3n",
+			args: []string{"-t:", "-k3,3n", files[1]},
+			out:  "root:x:0:0:root:/root:/bin/bash\nbin:x:2:2:bin:/bin:/bin/false\nwww:x:33:33:www:/var/www:/bin/sh\nnobody:x:65534:65534::/:/bin/false\n",
+		},
+		{
+			name: "documented -t : -k3,3n",
+			args: []string{"-t", ":", "-k3,3n", files[1]},
+			out:  "root:x:0:0:root:/root:/bin/bash\nbin:x:2:2:bin:/bin:/bin/false\nwww:x:33:33:www:/var/www:/bin/sh\nnobody:x:65534:65534::/:/bin/false\n",
+		},
+		{
+			name: "documented --csv -k2,2",
+			args: []string{"--csv", "-k2,2", files[2]},
+			out:  "2,Adams\n1,\"Doe, Jane\"\n3,\"Smith, John\"\nid,name\n",
+		},
+		{
+			name: "documented -k2,2n -k1,1r -k3.4,3.8",
+			args: []string{"-k2,2n", "-k1,1r", "-k3.4,3.8", files[0]},
+			out:  "c 2 z\nb 2 x\na 2 w\na 10 y\n",
+		},
+		{
+			name:  "equal keys compared as whole lines -k2n",
+			args:  []string{"-k2n"},
+			stdin: "b 2\na 10\nc 2\na 2\n",
+			out:   "a 2\nb 2\nc 2\na 10\n",
+		},
+		{
+			name:  "stable -s -k2n",
+			args:  []string{"-s", "-k2n"},
+			stdin: "b 2\na 10\nc 2\na 2\n",
+			out:   "b 2\nc 2\na 2\na 10\n",
+		},
+		{name: "multi-character attached separator", args: []string{"-t::"}, haveError: true},
+		{name: "unknown attached flag", args: []string{"-x1"}, haveError: true},
+		{name: "invalid attached key", args: []string{"-k0"}, haveError: true},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("sort", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			sc, err := parseArgs(flags, testingCase.args)
+			if testingCase.haveError {
+				if err == nil {
+					t.Errorf("expected error, but err is nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+
+			var out strings.Builder
+			if err := sortFiles(sc, strings.NewReader(testingCase.stdin), &out); err != nil {
+				t.Fatalf("expected err == nil; got '%s'", err.Error())
+			}
+			if out.String() != testingCase.out {
+				t.Errorf("expected result \n'%s';\n\ngot\n'%s'", testingCase.out, out.String())
+			}
+		})
+	}
+}
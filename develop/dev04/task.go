@@ -2,11 +2,8 @@ package main
 
 import (
 	"fmt"
-	"regexp"
 	"sort"
-	"strconv"
 	"strings"
-	"unicode/utf8"
 )
 
 /*
@@ -31,28 +28,42 @@ OK go vet -c=10 task.go
 OK golint task.go
 OK go test -run ''  (coverage: 90.9%)
 
+Все множества словаря - FindAnagramSets, анаграммы отдельных слов - Start.
+
 Запуск (слова для поиска анаграмм захардкожены в main()):
 go run task.go
 */
 
 // Dictionary - хранилище словаря в удобном для работы виде
 type Dictionary struct {
-	// мапа [кол-во_букв_в_слове]=слова_через_пробел
-	dict map[int]string
+	// мапа [сигнатура_слова]=слова_с_этой_сигнатурой в порядке добавления, без повторов;
+	// сигнатура - буквы слова по возрастанию, у анаграмм она одна и та же
+	index map[string][]string
+	// seen - слова, уже добавленные в index, для отбрасывания повторов
+	seen map[string]struct{}
 }
 
 // AddWords - функция добавления слов в словарь
 func (d *Dictionary) AddWords(words []string) {
 	for _, word := range words {
-		d.dict[utf8.RuneCountInString(word)] += strings.ToLower(strings.TrimSpace(word)) + " "
+		word = normalize(word)
+		if word == "" {
+			continue
+		}
+		if _, ok := d.seen[word]; ok {
+			continue
+		}
+		d.seen[word] = struct{}{}
+		key := signature(word)
+		d.index[key] = append(d.index[key], word)
 	}
-	//fmt.Printf("%#v", d.dict)
 }
 
 // NewDictionary - конструктор словаря
 func NewDictionary() *Dictionary {
 	return &Dictionary{
-		dict: make(map[int]string),
+		index: make(map[string][]string),
+		seen:  make(map[string]struct{}),
 	}
 }
 
@@ -64,29 +75,49 @@ func Start(wordList []string, dict *Dictionary) map[string][]string {
 		wordAnagrams := anagrams(word, dict)
 		if len(wordAnagrams) > 1 {
 			sort.Strings(wordAnagrams)
-			result[strings.ToLower(strings.TrimSpace(word))] = wordAnagrams
+			result[normalize(word)] = wordAnagrams
 		}
 	}
 	return result
 }
 
-func anagrams(word string, dict *Dictionary) []string {
-	validWord := strings.ToLower(strings.TrimSpace(word))
-	validWordLen := utf8.RuneCountInString(validWord)
-
-	// Формируем регулярное выражение вида [М,А,К,А,Р]{5}
-	var regExpr strings.Builder
-	regExpr.WriteString("[")
+// FindAnagramSets - все множества анаграмм словаря words: ключ - первое встретившееся слово
+// множества, значение - слова множества по возрастанию. Слова приводятся к нижнему регистру,
+// повторы отбрасываются, множества из одного слова в результат не попадают
+func FindAnagramSets(words []string) map[string][]string {
+	dict := NewDictionary()
+	dict.AddWords(words)
 
-	for _, symbol := range validWord {
-		regExpr.WriteString(string(symbol) + ",")
+	result := make(map[string][]string)
+	for _, set := range dict.index {
+		if len(set) < 2 {
+			continue
+		}
+		// слова множества хранятся в порядке добавления: первое - ключ
+		sorted := append([]string(nil), set...)
+		sort.Strings(sorted)
+		result[set[0]] = sorted
 	}
-	regExpr.WriteString("]{" + strconv.Itoa(validWordLen) + "}")
+	return result
+}
+
+// anagrams - слова словаря с той же сигнатурой, что у word (само слово тоже, если оно есть в словаре)
+func anagrams(word string, dict *Dictionary) []string {
+	found := dict.index[signature(normalize(word))]
+	// копия: вызывающий сортирует результат, порядок в словаре должен сохраниться
+	return append([]string(nil), found...)
+}
 
-	// поиск анаграм с помощью регулярных выражений
-	re := regexp.MustCompile(regExpr.String())
-	//fmt.Printf("%q\n", re.FindAllString(dict.dict[validWordLen], -1))
-	return re.FindAllString(dict.dict[validWordLen], -1)
+// normalize - слово в нижнем регистре без пробелов по краям
+func normalize(word string) string {
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+// signature - буквы слова по возрастанию: "тяпка" -> "акптя"
+func signature(word string) string {
+	runes := []rune(word)
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return string(runes)
 }
 
 func main() {
@@ -97,6 +128,7 @@ func main() {
 		"БАНЯ", "БАЯН", "КОРТ", "КРОТ", "ТРОК", "КОТ", "КТО", "ОТК", "ТОК",
 	})
 
-	//anagrams("кот", myDict)
 	fmt.Println(Start([]string{"кот"}, myDict))
+
+	fmt.Println(FindAnagramSets([]string{"пятак", "ПЯТКА", "тяпка", "листок", "слиток", "столик", "пятак", "стол"}))
 }
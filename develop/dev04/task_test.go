@@ -1,6 +1,8 @@
 package main
 
 import (
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -47,3 +49,74 @@ func TestUnpackString(t *testing.T) {
 		})
 	}
 }
+
+func TestAnagramsExactMatch(t *testing.T) {
+	myDict := NewDictionary()
+	myDict.AddWords([]string{"кот", "ККК", "ктт", "к,т", ",,,", "ток", " Ток ", "кто", "коты", "ко"})
+
+	testTable := []struct {
+		name string
+		in   string
+		out  []string
+	}{
+		// регулярное выражение [к,о,т,]{3} находило и "ккк", "ктт", "к,т", ",,,"
+		{name: "repeated letters and comma", in: "кот", out: []string{"кот", "кто", "ток"}},
+		{name: "word not in dictionary", in: "окт", out: []string{"кот", "кто", "ток"}},
+		{name: "repeated letters", in: "ккк", out: []string{"ккк"}},
+		{name: "comma", in: ",", out: nil},
+		{name: "bad-symbols", in: "[к", out: nil},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			result := anagrams(testingCase.in, myDict)
+			sort.Strings(result)
+			if !reflect.DeepEqual(result, testingCase.out) {
+				t.Errorf("expected %q; got %q", testingCase.out, result)
+			}
+		})
+	}
+}
+
+func TestFindAnagramSets(t *testing.T) {
+	testTable := []struct {
+		name string
+		in   []string
+		out  map[string][]string
+	}{
+		{
+			name: "example from task",
+			in:   []string{"пятак", "пятка", "тяпка", "листок", "слиток", "столик"},
+			out: map[string][]string{
+				"пятак":  {"пятак", "пятка", "тяпка"},
+				"листок": {"листок", "слиток", "столик"},
+			},
+		},
+		{
+			name: "first occurrence is the key",
+			in:   []string{"тяпка", "пятак", "пятка"},
+			out:  map[string][]string{"тяпка": {"пятак", "пятка", "тяпка"}},
+		},
+		{
+			name: "lower case and duplicates",
+			in:   []string{"Кот", "ТОК", "кот", " ток ", "кто"},
+			out:  map[string][]string{"кот": {"кот", "кто", "ток"}},
+		},
+		{
+			name: "singletons are skipped",
+			in:   []string{"стол", "кот", "КОТ", "ток"},
+			out:  map[string][]string{"кот": {"кот", "ток"}},
+		},
+		{name: "no sets", in: []string{"стол", "ккк", "кк"}, out: map[string][]string{}},
+		{name: "empty", in: []string{}, out: map[string][]string{}},
+	}
+
+	for _, testingCase := range testTable {
+		t.Run(testingCase.name, func(t *testing.T) {
+			result := FindAnagramSets(testingCase.in)
+			if !reflect.DeepEqual(result, testingCase.out) {
+				t.Errorf("expected %v; got %v", testingCase.out, result)
+			}
+		})
+	}
+}